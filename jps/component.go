@@ -0,0 +1,357 @@
+package jps
+
+import (
+	"slices"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+// component_compact_min is the fewest labels worth compacting
+const component_compact_min = 1024
+
+// ComponentIndex labels the connected walkable areas of the cells
+// [0, width) x [0, height) of a CellMap. MOVE_DIAG_ALWAYS and MOVE_ASTAR
+// connect diagonal cells, every other move mode only links cells through
+// their sides.
+type ComponentIndex struct {
+	cellMap  CellMap
+	width    int64
+	height   int64
+	diagonal bool
+
+	labels  []int32
+	parents []int32
+	live    int
+	queue   []geo.Vec2[int64]
+	fresh   []int32
+}
+
+func NewComponentIndex(cellMap CellMap, move int, width, height int64) *ComponentIndex {
+	index := new(ComponentIndex)
+	index.cellMap = cellMap
+	index.width = width
+	index.height = height
+	index.diagonal = move == MOVE_DIAG_ALWAYS || move == MOVE_ASTAR
+	index.Rebuild()
+
+	return index
+}
+
+// Rebuild labels every cell again, use it after changing many cells.
+func (index *ComponentIndex) Rebuild() {
+	index.labels = make([]int32, index.width*index.height)
+	index.parents = []int32{0}
+
+	pos := geo.Vec2[int64]{}
+	for pos.Y = 0; pos.Y < index.height; pos.Y++ {
+		for pos.X = 0; pos.X < index.width; pos.X++ {
+			if index.labels[index.offset(pos)] == 0 && index.cellMap.CanWalk(pos) {
+				index.fill(pos, index.newLabel())
+			}
+		}
+	}
+
+	index.live = len(index.parents) - 1
+}
+
+// Update refreshes the labels around pos after its walkability changed.
+// Opening a cell only merges the labels around it. Blocking a cell is as
+// cheap when its neighbors stay connected around it, otherwise every part of
+// its component is flooded again, which costs O(component).
+func (index *ComponentIndex) Update(pos geo.Vec2[int64]) {
+	if !index.contain(pos) {
+		return
+	}
+
+	offset := index.offset(pos)
+	walkable := index.cellMap.CanWalk(pos)
+	if walkable == (index.labels[offset] != 0) {
+		return
+	}
+
+	defer index.compact()
+
+	if walkable {
+		var label int32
+		index.eachNeighbor(pos, func(nPos geo.Vec2[int64]) {
+			nLabel := index.find(index.labels[index.offset(nPos)])
+			if label == 0 {
+				label = nLabel
+			} else if nLabel != label {
+				index.parents[nLabel] = label
+			}
+		})
+
+		if label == 0 {
+			label = index.newLabel()
+		}
+
+		index.labels[offset] = label
+		return
+	}
+
+	index.labels[offset] = 0
+	if index.linkedAround(pos) {
+		return
+	}
+
+	// removing a cell may split its component, every part touches pos so
+	// refilling from its neighbors relabels all of them
+	index.fresh = index.fresh[:0]
+	index.eachNeighbor(pos, func(nPos geo.Vec2[int64]) {
+		if slices.Contains(index.fresh, index.labels[index.offset(nPos)]) {
+			return
+		}
+
+		label := index.newLabel()
+		index.fresh = append(index.fresh, label)
+		index.fill(nPos, label)
+	})
+}
+
+// linkedAround tells whether the neighbors of pos connect through the cells
+// around it without pos, then blocking pos cannot split its component.
+func (index *ComponentIndex) linkedAround(pos geo.Vec2[int64]) bool {
+	var ring [8]geo.Vec2[int64]
+	var reached [8]bool
+	count := 0
+
+	for dy := int64(-1); dy <= 1; dy++ {
+		for dx := int64(-1); dx <= 1; dx++ {
+			cell := geo.Vec2[int64]{X: pos.X + dx, Y: pos.Y + dy}
+			if (dx != 0 || dy != 0) && index.contain(cell) && index.cellMap.CanWalk(cell) {
+				ring[count] = cell
+				count++
+			}
+		}
+	}
+
+	// spread from the first neighbor of pos over the ring
+	first := -1
+	index.eachNeighbor(pos, func(nPos geo.Vec2[int64]) {
+		if first < 0 {
+			first = slices.Index(ring[:count], nPos)
+		}
+	})
+
+	if first < 0 {
+		return true
+	}
+
+	stack := []int{first}
+	reached[first] = true
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for j := 0; j < count; j++ {
+			dx, dy := abs(ring[j].X-ring[i].X), abs(ring[j].Y-ring[i].Y)
+			if reached[j] || dx > 1 || dy > 1 || !index.diagonal && dx+dy > 1 {
+				continue
+			}
+
+			reached[j] = true
+			stack = append(stack, j)
+		}
+	}
+
+	linked := true
+	index.eachNeighbor(pos, func(nPos geo.Vec2[int64]) {
+		if !reached[slices.Index(ring[:count], nPos)] {
+			linked = false
+		}
+	})
+
+	return linked
+}
+
+// compact renumbers the labels once the merged and dropped ones outnumber
+// the live ones, so parents stays bounded however long the index lives.
+func (index *ComponentIndex) compact() {
+	if len(index.parents) <= max(component_compact_min, index.live*2) {
+		return
+	}
+
+	roots := make([]int32, len(index.parents))
+	for i := range roots {
+		roots[i] = index.find(int32(i))
+	}
+
+	dense := make([]int32, len(index.parents))
+	index.parents = index.parents[:1]
+	for i, v := range index.labels {
+		if v == 0 {
+			continue
+		}
+
+		root := roots[v]
+		if dense[root] == 0 {
+			dense[root] = index.newLabel()
+		}
+
+		index.labels[i] = dense[root]
+	}
+
+	index.live = len(index.parents) - 1
+}
+
+// Connected reports whether a path between the two cells can exist, cells
+// outside the index or blocked are never connected.
+func (index *ComponentIndex) Connected(pos1, pos2 geo.Vec2[int64]) bool {
+	label1, ok1 := index.Label(pos1)
+	label2, ok2 := index.Label(pos2)
+
+	return ok1 && ok2 && label1 == label2
+}
+
+func (index *ComponentIndex) Label(pos geo.Vec2[int64]) (int32, bool) {
+	if !index.contain(pos) {
+		return 0, false
+	}
+
+	label := index.labels[index.offset(pos)]
+	if label == 0 {
+		return 0, false
+	}
+
+	return index.find(label), true
+}
+
+// Nearest returns the cell of label's component closest to target.
+func (index *ComponentIndex) Nearest(label int32, target geo.Vec2[int64]) (geo.Vec2[int64], bool) {
+	found := false
+	var best geo.Vec2[int64]
+	var bestDistance int64
+
+	if index.width <= 0 || index.height <= 0 {
+		return best, false
+	}
+
+	// only the rings that cross the index hold cells of the label
+	minRadius := max(-target.X, target.X-index.width+1, -target.Y, target.Y-index.height+1, 0)
+	maxRadius := max(abs(target.X), abs(target.X-index.width+1), abs(target.Y), abs(target.Y-index.height+1))
+	for radius := minRadius; radius <= maxRadius; radius++ {
+		if found && radius*radius > bestDistance {
+			break
+		}
+
+		index.eachRing(target, radius, func(pos geo.Vec2[int64]) {
+			if l, ok := index.Label(pos); !ok || l != label {
+				return
+			}
+
+			distance := pos.Sub(target).LenSqr()
+			if !found || distance < bestDistance {
+				found = true
+				best = pos
+				bestDistance = distance
+			}
+		})
+	}
+
+	return best, found
+}
+
+func (index *ComponentIndex) newLabel() int32 {
+	label := int32(len(index.parents))
+	index.parents = append(index.parents, label)
+
+	return label
+}
+
+func (index *ComponentIndex) find(label int32) int32 {
+	for index.parents[label] != label {
+		index.parents[label] = index.parents[index.parents[label]]
+		label = index.parents[label]
+	}
+
+	return label
+}
+
+func (index *ComponentIndex) fill(start geo.Vec2[int64], label int32) {
+	index.labels[index.offset(start)] = label
+	index.queue = append(index.queue[:0], start)
+
+	for len(index.queue) > 0 {
+		pos := index.queue[len(index.queue)-1]
+		index.queue = index.queue[:len(index.queue)-1]
+
+		index.eachNeighbor(pos, func(nPos geo.Vec2[int64]) {
+			offset := index.offset(nPos)
+			if index.labels[offset] != label {
+				index.labels[offset] = label
+				index.queue = append(index.queue, nPos)
+			}
+		})
+	}
+}
+
+// eachNeighbor calls fn for every walkable neighbor of pos inside the index.
+func (index *ComponentIndex) eachNeighbor(pos geo.Vec2[int64], fn func(geo.Vec2[int64])) {
+	deltas := [16]int64{
+		0, -1, 1, 0, 0, 1, -1, 0,
+		-1, -1, 1, -1, 1, 1, -1, 1,
+	}
+
+	count := 8
+	if index.diagonal {
+		count = 16
+	}
+
+	for i := 0; i < count; i += 2 {
+		nPos := geo.Vec2[int64]{X: pos.X + deltas[i], Y: pos.Y + deltas[i+1]}
+		if index.contain(nPos) && index.cellMap.CanWalk(nPos) {
+			fn(nPos)
+		}
+	}
+}
+
+// eachRing visits the cells of the index at Chebyshev distance radius from
+// center.
+func (index *ComponentIndex) eachRing(center geo.Vec2[int64], radius int64, fn func(geo.Vec2[int64])) {
+	if radius == 0 {
+		if index.contain(center) {
+			fn(center)
+		}
+
+		return
+	}
+
+	minX, maxX := max(center.X-radius, 0), min(center.X+radius, index.width-1)
+	for _, y := range [2]int64{center.Y - radius, center.Y + radius} {
+		if y < 0 || y >= index.height {
+			continue
+		}
+
+		for x := minX; x <= maxX; x++ {
+			fn(geo.Vec2[int64]{X: x, Y: y})
+		}
+	}
+
+	minY, maxY := max(center.Y-radius+1, 0), min(center.Y+radius-1, index.height-1)
+	for _, x := range [2]int64{center.X - radius, center.X + radius} {
+		if x < 0 || x >= index.width {
+			continue
+		}
+
+		for y := minY; y <= maxY; y++ {
+			fn(geo.Vec2[int64]{X: x, Y: y})
+		}
+	}
+}
+
+func (index *ComponentIndex) contain(pos geo.Vec2[int64]) bool {
+	return pos.X >= 0 && pos.Y >= 0 && pos.X < index.width && pos.Y < index.height
+}
+
+func (index *ComponentIndex) offset(pos geo.Vec2[int64]) int64 {
+	return pos.Y*index.width + pos.X
+}
+
+func abs(a int64) int64 {
+	if a < 0 {
+		return -a
+	}
+
+	return a
+}
@@ -0,0 +1,50 @@
+package jps
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+func TestComponentNearest(t *testing.T) {
+	rnd := rand.New(rand.NewSource(8))
+
+	for round := 0; round < 20; round++ {
+		grid := randomGrid(rnd, 1+rnd.Int63n(20), 1+rnd.Int63n(20), 0.4)
+		index := NewComponentIndex(grid, MOVE_DIAG_NEVER, grid.Width(), grid.Height())
+
+		for i := 0; i < 20; i++ {
+			// targets inside, next to and far away from the index
+			spread := []int64{grid.Width() + grid.Height(), 5000}[i%2]
+			target := geo.Vec2[int64]{X: rnd.Int63n(2*spread) - spread/2, Y: rnd.Int63n(2*spread) - spread/2}
+
+			labels := make(map[int32]int64)
+			pos := geo.Vec2[int64]{}
+			for pos.Y = 0; pos.Y < grid.Height(); pos.Y++ {
+				for pos.X = 0; pos.X < grid.Width(); pos.X++ {
+					label, ok := index.Label(pos)
+					if !ok {
+						continue
+					}
+
+					distance := pos.Sub(target).LenSqr()
+					if old, ok := labels[label]; !ok || distance < old {
+						labels[label] = distance
+					}
+				}
+			}
+
+			for label, want := range labels {
+				nearest, ok := index.Nearest(label, target)
+				if l, _ := index.Label(nearest); !ok || l != label {
+					t.Fatalf("label %d target %v: got %v %v", label, target, nearest, ok)
+				}
+
+				if distance := nearest.Sub(target).LenSqr(); distance != want {
+					t.Errorf("label %d target %v: %v at %d, want %d", label, target, nearest, distance, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package jps
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+const (
+	TRACE_OPEN = iota
+	TRACE_CLOSE
+	TRACE_JUMP
+	TRACE_IMPROVE
+)
+
+const (
+	debug_cell_free = iota
+	debug_cell_block
+	debug_cell_jump
+	debug_cell_open
+	debug_cell_close
+	debug_cell_path
+	debug_cell_start
+	debug_cell_end
+)
+
+var debugChars = [...]byte{'.', '#', 'j', 'o', 'x', '*', 'S', 'E'}
+
+var debugPalette = color.Palette{
+	color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	color.RGBA{R: 0x40, G: 0x40, B: 0x40, A: 0xff},
+	color.RGBA{R: 0xf0, G: 0xd0, B: 0x40, A: 0xff},
+	color.RGBA{R: 0xa0, G: 0xe0, B: 0xa0, A: 0xff},
+	color.RGBA{R: 0xa0, G: 0xb0, B: 0xe0, A: 0xff},
+	color.RGBA{R: 0xe0, G: 0x30, B: 0x30, A: 0xff},
+	color.RGBA{R: 0x20, G: 0xa0, B: 0x20, A: 0xff},
+	color.RGBA{R: 0x20, G: 0x40, B: 0xd0, A: 0xff},
+}
+
+// TraceEvent is one step of a search, From is the cell being expanded.
+type TraceEvent struct {
+	Kind int
+	Pos  geo.Vec2[int64]
+	From geo.Vec2[int64]
+}
+
+// Trace keeps what a search did, Path runs from Start (excluded) to End.
+type Trace struct {
+	Start  geo.Vec2[int64]
+	End    geo.Vec2[int64]
+	Events []TraceEvent
+	Path   []geo.Vec2[int64]
+
+	started bool
+}
+
+func (trace *Trace) Reset() {
+	trace.Events = trace.Events[:0]
+	trace.Path = trace.Path[:0]
+	trace.started = false
+}
+
+func (trace *Trace) begin(start, end geo.Vec2[int64]) {
+	if !trace.started {
+		trace.Start = start
+		trace.started = true
+	}
+
+	trace.End = end
+}
+
+func (trace *Trace) OnOpen(pos, parent geo.Vec2[int64], g, h float64) {
+	trace.add(TRACE_OPEN, pos, parent)
+}
+
+func (trace *Trace) OnClose(pos geo.Vec2[int64], g float64) {
+	trace.add(TRACE_CLOSE, pos, pos)
+}
+
+func (trace *Trace) OnJump(from, to geo.Vec2[int64]) {
+	trace.add(TRACE_JUMP, to, from)
+}
+
+func (trace *Trace) OnImprove(pos, parent geo.Vec2[int64], oldG, newG float64) {
+	trace.add(TRACE_IMPROVE, pos, parent)
+}
+
+func (trace *Trace) add(kind int, pos, from geo.Vec2[int64]) {
+	trace.Events = append(trace.Events, TraceEvent{Kind: kind, Pos: pos, From: from})
+}
+
+// addPath appends a leg given from its end back to its start.
+func (trace *Trace) addPath(leg []geo.Vec2[int64]) {
+	start := len(trace.Path)
+	trace.Path = append(trace.Path, leg...)
+	slices.Reverse(trace.Path[start:])
+}
+
+// RenderASCII draws the cells of region, [X, X+Width] x [Y, Y+Height] like
+// geo.Rect.Contain and FindOptWithin, one character per cell: '#' blocked,
+// 'j' jump point never opened, 'o' open, 'x' closed, '*' path, 'S' start and
+// 'E' end. trace may be nil.
+func RenderASCII(cellMap CellMap, region geo.Rect[int64], trace *Trace) string {
+	cells := debugCells(cellMap, region, trace, -1)
+	width, height := debugSize(region)
+
+	var builder strings.Builder
+	builder.Grow(int((width + 1) * height))
+
+	for y := int64(0); y < height; y++ {
+		for x := int64(0); x < width; x++ {
+			builder.WriteByte(debugChars[cells[y*width+x]])
+		}
+
+		builder.WriteByte('\n')
+	}
+
+	return builder.String()
+}
+
+// RenderImage draws region like RenderASCII with scale x scale pixels per cell.
+func RenderImage(cellMap CellMap, region geo.Rect[int64], trace *Trace, scale int) *image.Paletted {
+	return debugImage(debugCells(cellMap, region, trace, -1), region, scale)
+}
+
+func RenderPNG(w io.Writer, cellMap CellMap, region geo.Rect[int64], trace *Trace, scale int) error {
+	return png.Encode(w, RenderImage(cellMap, region, trace, scale))
+}
+
+// RenderGIF animates trace, every frame adds eventsPerFrame events and the
+// last frame shows the path. delay is the frame time in 1/100 seconds.
+func RenderGIF(w io.Writer, cellMap CellMap, region geo.Rect[int64], trace *Trace, scale, eventsPerFrame, delay int) error {
+	if eventsPerFrame < 1 {
+		eventsPerFrame = 1
+	}
+
+	anim := &gif.GIF{}
+	for events := 0; ; events += eventsPerFrame {
+		last := events >= len(trace.Events)
+		if last {
+			events = -1
+		}
+
+		anim.Image = append(anim.Image, debugImage(debugCells(cellMap, region, trace, events), region, scale))
+		anim.Delay = append(anim.Delay, delay)
+
+		if last {
+			break
+		}
+	}
+
+	return gif.EncodeAll(w, anim)
+}
+
+// debugCells classifies every cell of region after the first events events of
+// trace, a negative count takes all events and the path.
+func debugCells(cellMap CellMap, region geo.Rect[int64], trace *Trace, events int) []uint8 {
+	width, height := debugSize(region)
+	cells := make([]uint8, width*height)
+	pos := geo.Vec2[int64]{}
+
+	set := func(pos geo.Vec2[int64], kind uint8) {
+		if !region.Contain(pos) {
+			return
+		}
+
+		if offset := (pos.Y-region.Y)*width + pos.X - region.X; cells[offset] < kind {
+			cells[offset] = kind
+		}
+	}
+
+	for pos.Y = region.Y; pos.Y < region.Y+height; pos.Y++ {
+		for pos.X = region.X; pos.X < region.X+width; pos.X++ {
+			if !cellMap.CanWalk(pos) {
+				set(pos, debug_cell_block)
+			}
+		}
+	}
+
+	if trace == nil {
+		return cells
+	}
+
+	list := trace.Events
+	if events >= 0 && events < len(list) {
+		list = list[:events]
+	}
+
+	for _, v := range list {
+		switch v.Kind {
+		case TRACE_OPEN, TRACE_IMPROVE:
+			set(v.Pos, debug_cell_open)
+		case TRACE_CLOSE:
+			set(v.Pos, debug_cell_close)
+		case TRACE_JUMP:
+			set(v.Pos, debug_cell_jump)
+		}
+	}
+
+	if events < 0 {
+		prev := trace.Start
+		for _, v := range trace.Path {
+			dx, dy := dir(v, prev)
+			for cell := prev; cell != v; {
+				cell.X += dx
+				cell.Y += dy
+				set(cell, debug_cell_path)
+			}
+
+			prev = v
+		}
+	}
+
+	if trace.started {
+		set(trace.Start, debug_cell_start)
+		set(trace.End, debug_cell_end)
+	}
+
+	return cells
+}
+
+// debugSize counts the columns and rows of cells region contains.
+func debugSize(region geo.Rect[int64]) (int64, int64) {
+	return max(region.Width+1, 0), max(region.Height+1, 0)
+}
+
+func debugImage(cells []uint8, region geo.Rect[int64], scale int) *image.Paletted {
+	if scale < 1 {
+		scale = 1
+	}
+
+	regionWidth, regionHeight := debugSize(region)
+	width, height := int(regionWidth), int(regionHeight)
+	img := image.NewPaletted(image.Rect(0, 0, width*scale, height*scale), debugPalette)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			index := cells[y*width+x]
+			for py := y * scale; py < (y+1)*scale; py++ {
+				for px := x * scale; px < (x+1)*scale; px++ {
+					img.SetColorIndex(px, py, index)
+				}
+			}
+		}
+	}
+
+	return img
+}
@@ -0,0 +1,36 @@
+package jps
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+func TestRenderASCIIRegion(t *testing.T) {
+	sketch := strings.TrimSpace(fixedMaps[2])
+	grid, _, err := ParseGridMap(sketch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the region FindOptWithin limits a search of the whole map to
+	region := geo.Rect[int64]{X: 0, Y: 0, Width: grid.Width() - 1, Height: grid.Height() - 1}
+	want := strings.NewReplacer("S", ".", "G", ".").Replace(sketch) + "\n"
+	if got := RenderASCII(grid, region, nil); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// the trace of a search within region fits the same drawing
+	trace := &Trace{}
+	finder := NewFinder(grid, MOVE_DIAG_NO_OBS)
+	start, end := geo.Vec2[int64]{X: 0, Y: 0}, geo.Vec2[int64]{X: grid.Width() - 1, Y: grid.Height() - 1}
+	if _, err := finder.FindPath(start, end, FindOptWithin(region), FindOptTrace(trace)); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(RenderASCII(grid, region, trace)), "\n")
+	if lines[0][0] != 'S' || lines[len(lines)-1][len(lines[0])-1] != 'E' {
+		t.Errorf("start or end missing from\n%s", strings.Join(lines, "\n"))
+	}
+}
@@ -0,0 +1,54 @@
+package jps
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+var (
+	ErrUnreachable = errors.New("jps: end point unreachable")
+	ErrPathBlocked = errors.New("jps: path enters a blocked cell")
+	ErrPathNotLine = errors.New("jps: path step is not a straight or diagonal line")
+	ErrPathCorner  = errors.New("jps: path step cuts a corner")
+	ErrBudget      = errors.New("jps: search budget exhausted")
+
+	ErrLandmarkFormat   = errors.New("jps: bad landmark data")
+	ErrSubgoalFormat    = errors.New("jps: bad subgoal graph data")
+	ErrWalkGridFormat   = errors.New("jps: bad walk grid data")
+	ErrWalkGridChecksum = errors.New("jps: walk grid checksum mismatch")
+	ErrDeadline         = errors.New("jps: request deadline passed before search")
+	ErrServiceClosed    = errors.New("jps: service closed")
+)
+
+// LegError tells which leg of a multi point route could not be found.
+type LegError struct {
+	Leg  int
+	From geo.Vec2[int64]
+	To   geo.Vec2[int64]
+	Err  error
+}
+
+func (e *LegError) Error() string {
+	return fmt.Sprintf("leg %d from %v to %v: %v", e.Leg, e.From, e.To, e.Err)
+}
+
+func (e *LegError) Unwrap() error {
+	return e.Err
+}
+
+// PathError tells which step of a path is invalid, Pos is the offending cell.
+type PathError struct {
+	Index int
+	Pos   geo.Vec2[int64]
+	Err   error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("step %d at %v: %v", e.Index, e.Pos, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
@@ -1,10 +1,11 @@
 package jps
 
 import (
+	"math"
 	"slices"
 
-	"github.com/xtxy/cxlib/logs"
 	"github.com/xtxy/cxlib/geo"
+	"github.com/xtxy/cxlib/logs"
 )
 
 const (
@@ -14,6 +15,11 @@ const (
 	CELL_STATE_BLOCK
 )
 
+const (
+	COST_FLOAT = iota
+	COST_FIXED
+)
+
 const (
 	MOVE_DIAG_NEVER = iota
 	MOVE_DIAG_NO_OBS
@@ -35,60 +41,174 @@ type CellMap interface {
 	CanWalk(pos geo.Vec2[int64]) bool
 }
 
+// CostCellMap is an optional CellMap extension, the cost of a cell is added to
+// every step entering it like a FindOptPenalties penalty.
+type CostCellMap interface {
+	CellMap
+	GetCost(pos geo.Vec2[int64]) float64
+}
+
+// CapabilityCellMap is an optional CellMap extension for cells only some units
+// may enter, e.g. water for swimmers or locked doors for key holders. caps is
+// the capability mask of the unit given with FindOptCapabilities.
+type CapabilityCellMap interface {
+	CellMap
+	CanWalkWith(pos geo.Vec2[int64], caps uint64) bool
+}
+
 type jpsMove interface {
 	findNeighbors(geo.Vec2[int64]) []geo.Vec2[int64]
 	jump(pos geo.Vec2[int64], parent geo.Vec2[int64]) (geo.Vec2[int64], bool)
 }
 
 type Finder struct {
-	cellMap CellMap
-	endPos  geo.Vec2[int64]
-	move    jpsMove
-
-	opens map[geo.Vec2[int64]]struct{}
-	start geo.Vec2[int64]
-	end geo.Vec2[int64]
+	cellMap  CellMap
+	costMap  CostCellMap
+	capMap   CapabilityCellMap
+	startPos geo.Vec2[int64]
+	endPos   geo.Vec2[int64]
+	move     jpsMove
+	moves    [MOVE_ASTAR + 1]jpsMove
+
+	defaultMove      int
+	defaultHeuristic Heuristic
+	costMode         int
+	components       [2]*ComponentIndex
+	influence        *InfluenceMap
+
+	opens       openList[geo.Vec2[int64]]
+	budget      searchBudget
+	budgetLimit int
+	pathCost    float64
 	nearest     bool
 	reversePath bool
-	blocks      map[string]struct{}
+	blocks      map[geo.Vec2[int64]]struct{}
+	blockFunc   func(geo.Vec2[int64]) bool
+	penalties   map[geo.Vec2[int64]]float64
+	penaltyFunc func(geo.Vec2[int64]) float64
+	layers      []penaltyLayer
+	turnPenalty float64
+	turns       *GraphFinder[turnState]
+	within      *geo.Rect[int64]
+	maxRadius   int64
+	trace       *Trace
+	observers   []Observer
+	moveType    int
+	agentSize   int64
+	caps        uint64
+	hasCaps     bool
+	heuristic   Heuristic
 }
 
 func NewFinder(cellMap CellMap, move int) *Finder {
+	if !validMove(move) {
+		logs.Error("unkown.move.type:", move)
+		return nil
+	}
+
 	finder := new(Finder)
 	finder.cellMap = cellMap
+	finder.costMap, _ = cellMap.(CostCellMap)
+	finder.capMap, _ = cellMap.(CapabilityCellMap)
+	finder.defaultMove = move
+	finder.defaultHeuristic = HeuristicManhattan
+	finder.opens.less = lessPos
+
+	return finder
+}
+
+func validMove(move int) bool {
+	return move >= MOVE_DIAG_NEVER && move <= MOVE_ASTAR
+}
+
+func (finder *Finder) getMove(move int) jpsMove {
+	if finder.moves[move] != nil {
+		return finder.moves[move]
+	}
+
+	var moveInstance jpsMove
 
 	switch move {
 	case MOVE_DIAG_ALWAYS:
-		moveInstance := new(jpsMoveDiag)
-		moveInstance.finder = finder
-		finder.move = moveInstance
+		moveInstance = &jpsMoveDiag{finder: finder}
 
 	case MOVE_DIAG_MOST_ONE:
-		moveInstance := new(jpsMoveDiagOne)
-		moveInstance.finder = finder
-		finder.move = moveInstance
+		moveInstance = &jpsMoveDiagOne{finder: finder}
 
 	case MOVE_DIAG_NO_OBS:
-		moveInstance := new(jpsMoveDiagNoObs)
-		moveInstance.finder = finder
-		finder.move = moveInstance
+		moveInstance = &jpsMoveDiagNoObs{finder: finder}
 
 	case MOVE_DIAG_NEVER:
-		moveInstance := new(jpsMoveDiagNever)
-		moveInstance.finder = finder
-		finder.move = moveInstance
+		moveInstance = &jpsMoveDiagNever{finder: finder}
 
 	case MOVE_ASTAR:
-		moveInstance := new(jpsMoveNone)
-		moveInstance.finder = finder
-		finder.move = moveInstance
+		moveInstance = &jpsMoveNone{finder: finder}
+	}
+
+	finder.moves[move] = moveInstance
+	return moveInstance
+}
+
+// SetHeuristic changes the heuristic used by queries without FindOptHeuristic.
+func (finder *Finder) SetHeuristic(heuristic Heuristic) {
+	if heuristic == nil {
+		heuristic = HeuristicManhattan
+	}
+
+	finder.defaultHeuristic = heuristic
+}
+
+// SetCostMode switches between float64 euclidean step costs (COST_FLOAT) and
+// integer octile costs (COST_FIXED) that give bit identical paths on every
+// platform, it also resets the default heuristic to the one of the mode. In
+// COST_FIXED mode costs are in FIXED_COST_STRAIGHT units per cell, penalties
+// are scaled and rounded to them and heuristics have to return them.
+func (finder *Finder) SetCostMode(mode int) {
+	switch mode {
+	case COST_FIXED:
+		finder.defaultHeuristic = HeuristicFixedOctile
+
+	case COST_FLOAT:
+		finder.defaultHeuristic = HeuristicManhattan
 
 	default:
-		logs.Error("unkown.move.type:", move)
+		logs.Error("unkown.cost.mode:", mode)
+		return
+	}
+
+	finder.costMode = mode
+}
+
+// SetComponentIndex lets queries fail at once when start and end lie in
+// different areas. An index built for a diagonal move mode serves every
+// mode, one built for a side only mode serves the side only modes.
+func (finder *Finder) SetComponentIndex(index *ComponentIndex) {
+	if index.diagonal {
+		finder.components[1] = index
+	} else {
+		finder.components[0] = index
+	}
+}
+
+func (finder *Finder) getComponentIndex() *ComponentIndex {
+	if finder.hasCaps {
 		return nil
 	}
 
-	return finder
+	if finder.moveType == MOVE_DIAG_ALWAYS || finder.moveType == MOVE_ASTAR {
+		return finder.components[1]
+	}
+
+	if finder.components[0] != nil {
+		return finder.components[0]
+	}
+
+	return finder.components[1]
+}
+
+// SetInfluenceMap gives FindOptPenaltyLayer its layers.
+func (finder *Finder) SetInfluenceMap(influence *InfluenceMap) {
+	finder.influence = influence
 }
 
 type FindOption func(finder *Finder)
@@ -99,52 +219,271 @@ func FindOptNearest(nearest bool) FindOption {
 	}
 }
 
-func FindOptBlocks(blocks map[string]struct{}) FindOption {
+// FindOptBlocks treats the given cells as obstacles for one query, the
+// CellMap itself is left untouched.
+func FindOptBlocks(blocks map[geo.Vec2[int64]]struct{}) FindOption {
 	return func(finder *Finder) {
 		finder.blocks = blocks
 	}
 }
 
+func FindOptBlockFunc(isBlock func(pos geo.Vec2[int64]) bool) FindOption {
+	return func(finder *Finder) {
+		finder.blockFunc = isBlock
+	}
+}
+
+// FindOptPenalties adds an extra cost for entering the given cells instead of
+// blocking them. Jump modes charge the penalties of every cell they jump over
+// but still prune as if the grid was uniform, only MOVE_ASTAR guarantees the
+// cheapest path.
+func FindOptPenalties(penalties map[geo.Vec2[int64]]float64) FindOption {
+	return func(finder *Finder) {
+		finder.penalties = penalties
+	}
+}
+
+func FindOptPenaltyFunc(penalty func(pos geo.Vec2[int64]) float64) FindOption {
+	return func(finder *Finder) {
+		finder.penaltyFunc = penalty
+	}
+}
+
+// FindOptPenaltyLayer adds weight times the value of an influence map layer
+// to the cost of entering every cell, negative products count as 0. It can
+// be given several times and prunes like FindOptPenalties.
+func FindOptPenaltyLayer(name string, weight float64) FindOption {
+	return func(finder *Finder) {
+		if finder.influence == nil {
+			logs.Error("influence.map.not.set:", name)
+			return
+		}
+
+		values := finder.influence.getValues(name)
+		if values == nil {
+			logs.Error("influence.layer.not.found:", name)
+			return
+		}
+
+		finder.layers = append(finder.layers, penaltyLayer{values: values, weight: weight})
+	}
+}
+
 func FindOptReversePath(reverse bool) FindOption {
 	return func(finder *Finder) {
 		finder.reversePath = reverse
 	}
 }
 
+// FindOptMove overrides the diagonal rule given to NewFinder for one query.
+func FindOptMove(move int) FindOption {
+	return func(finder *Finder) {
+		if !validMove(move) {
+			logs.Error("unkown.move.type:", move)
+			return
+		}
+
+		finder.moveType = move
+	}
+}
+
+// FindOptAgentSize makes the query walk a size x size agent whose top left
+// cell is the path position, every covered cell has to be walkable.
+func FindOptAgentSize(size int64) FindOption {
+	return func(finder *Finder) {
+		if size < 1 {
+			size = 1
+		}
+
+		finder.agentSize = size
+	}
+}
+
+// FindOptCapabilities walks the cells the CellMap allows for units with the
+// capability mask caps, it needs a CapabilityCellMap. The component index is
+// skipped because it only knows the cells every unit can walk.
+func FindOptCapabilities(caps uint64) FindOption {
+	return func(finder *Finder) {
+		if finder.capMap == nil {
+			logs.Error("cell.map.without.capabilities:", caps)
+			return
+		}
+
+		finder.caps = caps
+		finder.hasCaps = true
+	}
+}
+
+func FindOptHeuristic(heuristic Heuristic) FindOption {
+	return func(finder *Finder) {
+		if heuristic != nil {
+			finder.heuristic = heuristic
+		}
+	}
+}
+
+// FindOptWithin treats every cell outside rect as blocked.
+func FindOptWithin(rect geo.Rect[int64]) FindOption {
+	return func(finder *Finder) {
+		finder.within = &rect
+	}
+}
+
+// FindOptMaxRadius treats every cell farther than radius from the start as
+// blocked.
+func FindOptMaxRadius(radius int64) FindOption {
+	return func(finder *Finder) {
+		finder.maxRadius = radius
+	}
+}
+
+// FindOptBudget stops the query after expanding nodes cells with
+// ErrBudget, or with the path to the closest cell seen under FindOptNearest.
+func FindOptBudget(nodes int) FindOption {
+	return func(finder *Finder) {
+		finder.budgetLimit = nodes
+	}
+}
+
+// FindOptObserver reports the search steps of one query to observer, it can
+// be given several times.
+func FindOptObserver(observer Observer) FindOption {
+	return func(finder *Finder) {
+		finder.observers = append(finder.observers, observer)
+	}
+}
+
+// FindOptTrace records the search into trace for debugging, see RenderASCII.
+func FindOptTrace(trace *Trace) FindOption {
+	return func(finder *Finder) {
+		trace.Reset()
+		finder.trace = trace
+		finder.observers = append(finder.observers, trace)
+	}
+}
+
 func (finder *Finder) Find(start, end geo.Vec2[int64], options ...FindOption) []geo.Vec2[int64] {
-	if !finder.cellMap.CanWalk(start) {
-		logs.Warning("start.point.in.block:", start)
+	list, _ := finder.FindPath(start, end, options...)
+	return list
+}
+
+// FindResult works like FindPath and also returns the path cost and the
+// number of expanded cells.
+func (finder *Finder) FindResult(start, end geo.Vec2[int64], options ...FindOption) (Result[geo.Vec2[int64]], error) {
+	list, err := finder.FindPath(start, end, options...)
+
+	return Result[geo.Vec2[int64]]{
+		Path:     list,
+		Cost:     finder.pathCost,
+		Expanded: finder.budget.expanded,
+	}, err
+}
+
+// FindPath works like Find but reports why no path was returned.
+func (finder *Finder) FindPath(start, end geo.Vec2[int64], options ...FindOption) ([]geo.Vec2[int64], error) {
+	finder.applyOptions(options)
+
+	list, err := finder.search(start, end, make([]geo.Vec2[int64], 0))
+	if err != nil {
+		return nil, err
 	}
 
+	if finder.reversePath && len(list) > 0 {
+		slices.Reverse(list)
+	}
+
+	return list, nil
+}
+
+func (finder *Finder) applyOptions(options []FindOption) {
 	finder.nearest = false
+	finder.reversePath = false
 	finder.blocks = nil
+	finder.blockFunc = nil
+	finder.penalties = nil
+	finder.penaltyFunc = nil
+	clear(finder.layers)
+	finder.layers = finder.layers[:0]
+	finder.within = nil
+	finder.maxRadius = 0
+	finder.turnPenalty = 0
+	finder.budgetLimit = 0
+	finder.trace = nil
+	clear(finder.observers)
+	finder.observers = finder.observers[:0]
+	finder.moveType = finder.defaultMove
+	finder.agentSize = 1
+	finder.caps = 0
+	finder.hasCaps = false
+	finder.heuristic = finder.defaultHeuristic
 
 	for _, v := range options {
 		v(finder)
 	}
 
+	finder.move = finder.getMove(finder.moveType)
+}
+
+// search appends the path from end back to start (start excluded) to list.
+func (finder *Finder) search(start, end geo.Vec2[int64], list []geo.Vec2[int64]) ([]geo.Vec2[int64], error) {
+	finder.startPos = start
+	finder.pathCost = 0
+	finder.budget.reset(finder.budgetLimit)
+
+	if !finder.canWalk(start) {
+		logs.Warning("start.point.in.block:", start)
+	}
+
+	if !finder.nearest && !finder.inBounds(end) {
+		return list, ErrUnreachable
+	}
+
+	if index := finder.getComponentIndex(); index != nil {
+		if label, ok := index.Label(start); ok && !index.Connected(start, end) {
+			if !finder.nearest {
+				return list, ErrUnreachable
+			}
+
+			if nearestPos, ok := index.Nearest(label, end); ok {
+				end = nearestPos
+			}
+		}
+	}
+
 	defer finder.cellMap.Reset()
 
-	if !finder.cellMap.CanWalk(end) || finder.cellMap.GetState(end) == CELL_STATE_BLOCK && !finder.nearest {
+	if !finder.canWalk(end) && !finder.nearest {
 		logs.Error("end.point.in.block:", end)
 	}
 
+	if finder.trace != nil {
+		finder.trace.begin(start, end)
+	}
+
+	if finder.turnPenalty > 0 && finder.moveType == MOVE_ASTAR {
+		// with FindOptNearest an unreachable end falls back to the search
+		// below, which charges the turns between cells only
+		if list, err := finder.searchTurns(start, end, list); err == nil || !finder.nearest {
+			return list, err
+		}
+	}
+
 	finder.endPos = end
 	found := false
 	foundNearest := false
 	nearestPos := geo.Vec2[int64]{}
-	finder.opens = map[geo.Vec2[int64]]struct{}{
-		start: struct{}{},
-	}
 	var nearestDistance int64 = 0
 
-	for len(finder.opens) > 0 && !found {
-		pos := finder.getMinFPos()
+	err := searchLoop[geo.Vec2[int64]](finder, &finder.opens, &finder.budget, start, 0, func(pos geo.Vec2[int64], g float64) bool {
+		if len(finder.observers) > 0 {
+			for _, v := range finder.observers {
+				v.OnClose(pos, g)
+			}
+		}
 
-		finder.cellMap.SetState(pos, CELL_STATE_CLOSE)
 		if pos == finder.endPos {
 			found = true
-			break
+			return false
 		}
 
 		if finder.nearest {
@@ -156,40 +495,46 @@ func (finder *Finder) Find(start, end geo.Vec2[int64], options ...FindOption) []
 			}
 		}
 
-		finder.identifySuccessors(pos, end)
-	}
+		return true
+	})
 
 	if !found {
 		if finder.nearest && foundNearest {
 			end = nearestPos
 		} else {
-			return nil
+			return list, err
 		}
 	}
 
-	list := make([]geo.Vec2[int64], 0)
+	finder.pathCost = finder.cellMap.GetG(end)
+
+	legStart := len(list)
 	for ; end != start; end, _ = finder.cellMap.GetParent(end) {
 		list = append(list, end)
 	}
 
-	if finder.reversePath && len(list) > 0 {
-		slices.Reverse(list)
+	if finder.trace != nil {
+		finder.trace.addPath(list[legStart:])
 	}
 
-	return list
+	return list, nil
 }
 
-func (finder *Finder) identifySuccessors(pos, end geo.Vec2[int64]) {
-	if parent, ok := finder.cellMap.GetParent(pos); !ok {
-		deltas := [8]int64{
-			0, -1, 0, 1, -1, 0, 1, 0,
-		}
+func (finder *Finder) isClosed(pos geo.Vec2[int64]) bool {
+	return finder.cellMap.GetState(pos) == CELL_STATE_CLOSE
+}
 
-		for i := 0; i < len(deltas); i += 2 {
-			finder.search(pos, deltas[i], deltas[i+1])
-		}
-	}
+func (finder *Finder) closeNode(pos geo.Vec2[int64]) float64 {
+	finder.cellMap.SetState(pos, CELL_STATE_CLOSE)
 
+	return finder.cellMap.GetG(pos)
+}
+
+func (finder *Finder) expandNode(pos geo.Vec2[int64], g float64) {
+	finder.identifySuccessors(pos, finder.endPos)
+}
+
+func (finder *Finder) identifySuccessors(pos, end geo.Vec2[int64]) {
 	srcG := finder.cellMap.GetG(pos)
 	neighbors := finder.move.findNeighbors(pos)
 	for _, v := range neighbors {
@@ -198,106 +543,154 @@ func (finder *Finder) identifySuccessors(pos, end geo.Vec2[int64]) {
 			continue
 		}
 
+		if len(finder.observers) > 0 {
+			for _, v := range finder.observers {
+				v.OnJump(pos, jumpPos)
+			}
+		}
+
 		if finder.cellMap.GetState(jumpPos) == CELL_STATE_CLOSE {
 			continue
 		}
 
-		newG := getG(jumpPos, pos) + srcG
+		newG := finder.getStepCost(pos, jumpPos) + srcG
+		if finder.turnPenalty > 0 && pos != finder.startPos {
+			parent, _ := finder.cellMap.GetParent(pos)
+			dx1, dy1 := dir(pos, parent)
+			dx2, dy2 := dir(jumpPos, pos)
+			newG += finder.getTurnCost(dx1, dy1, dx2, dy2)
+		}
 
 		if finder.cellMap.GetState(jumpPos) != CELL_STATE_OPEN {
 			finder.cellMap.SetState(jumpPos, CELL_STATE_OPEN)
 			finder.cellMap.SetG(jumpPos, newG)
-			finder.cellMap.SetH(jumpPos, getH(jumpPos, end))
+			finder.cellMap.SetH(jumpPos, finder.heuristic(jumpPos, end))
 			finder.cellMap.SetParent(jumpPos, pos)
 
-			finder.opens[jumpPos] = struct{}{}
-		} else if newG < finder.cellMap.GetG(jumpPos) {
+			finder.opens.Push(jumpPos, newG, finder.cellMap.GetH(jumpPos))
+			if len(finder.observers) > 0 {
+				for _, v := range finder.observers {
+					v.OnOpen(jumpPos, pos, newG, finder.cellMap.GetH(jumpPos))
+				}
+			}
+		} else if oldG := finder.cellMap.GetG(jumpPos); newG < oldG {
 			finder.cellMap.SetG(jumpPos, newG)
 			finder.cellMap.SetParent(jumpPos, pos)
+			finder.opens.Push(jumpPos, newG, finder.cellMap.GetH(jumpPos))
+
+			if len(finder.observers) > 0 {
+				for _, v := range finder.observers {
+					v.OnImprove(jumpPos, pos, oldG, newG)
+				}
+			}
 		}
 	}
 }
 
 func (finder *Finder) canWalk(pos geo.Vec2[int64]) bool {
-	return finder.cellMap.CanWalk(pos) && finder.cellMap.GetState(pos) != CELL_STATE_BLOCK
-}
+	if finder.cellMap.GetState(pos) == CELL_STATE_BLOCK {
+		return false
+	}
 
-func (finder *Finder) search(pos geo.Vec2[int64], deltaX, deltaY int64) {
-	next := geo.Vec2[int64]{X: pos.X + deltaX, Y: pos.Y + deltaY}
-	if !finder.cellMap.CanWalk(next) {
-		return
+	if finder.agentSize <= 1 {
+		return finder.cellCanWalk(pos)
 	}
 
-	prevPos := pos
+	cell := geo.Vec2[int64]{}
+	for cell.Y = pos.Y; cell.Y < pos.Y+finder.agentSize; cell.Y++ {
+		for cell.X = pos.X; cell.X < pos.X+finder.agentSize; cell.X++ {
+			if !finder.cellCanWalk(cell) {
+				return false
+			}
+		}
+	}
 
-	for {
-		if !finder.cellMap.CanWalk(next) || finder.cellMap.GetState(next) == CELL_STATE_OPEN {
-			break
+	return true
+}
+
+func (finder *Finder) cellCanWalk(pos geo.Vec2[int64]) bool {
+	if finder.hasCaps {
+		if !finder.capMap.CanWalkWith(pos, finder.caps) {
+			return false
 		}
+	} else if !finder.cellMap.CanWalk(pos) {
+		return false
+	}
 
-		if _, ok := finder.isJumpPoint(prevPos, next, deltaX, deltaY); ok {
-			finder.insertOpens(next, pos)
-			break
+	if finder.blocks != nil {
+		if _, ok := finder.blocks[pos]; ok {
+			return false
 		}
+	}
 
-		prevPos = next
-		next.X += deltaX
-		next.Y += deltaY
+	if finder.blockFunc != nil && finder.blockFunc(pos) {
+		return false
 	}
+
+	return finder.inBounds(pos)
 }
 
-func (finder *Finder) isJumpPoint(prevPos, pos geo.Vec2[int64], deltaX, deltaY int64) (jumpPos geo.Vec2[int64], ok bool) {
-	if !finder.cellMap.CanWalk(pos) {
-		return
+func (finder *Finder) inBounds(pos geo.Vec2[int64]) bool {
+	if finder.within != nil && !finder.within.Contain(pos) {
+		return false
 	}
 
-	if pos == finder.start || pos == finder.end {
-		jumpPos = pos
-		ok = true
-		return
+	if finder.maxRadius > 0 && pos.Sub(finder.startPos).LenSqr() > finder.maxRadius*finder.maxRadius {
+		return false
 	}
 
-	if finder.hasForceNeighbor(prevPos, pos) {
-		jumpPos = pos
-		ok = true
-		return
+	return true
+}
+
+func (finder *Finder) getStepCost(from, to geo.Vec2[int64]) float64 {
+	var cost float64
+	if finder.costMode == COST_FIXED {
+		cost = getFixedG(to, from)
+	} else {
+		cost = getG(to, from)
 	}
 
-	if deltaX == 0 || deltaY == 0 {
-		return
+	if finder.penalties == nil && finder.penaltyFunc == nil && finder.costMap == nil && len(finder.layers) == 0 {
+		return cost
 	}
 
-	jumpPos, ok = finder.jumpSearchHV(pos, deltaX, 0)
-	if ok {
-		return
+	dx, dy := dir(to, from)
+	for pos := from; pos != to; {
+		pos.X += dx
+		pos.Y += dy
+		if finder.costMode == COST_FIXED {
+			cost += math.Round(finder.getPenalty(pos) * FIXED_COST_STRAIGHT)
+		} else {
+			cost += finder.getPenalty(pos)
+		}
 	}
 
-	jumpPos, ok = finder.jumpSearchHV(pos, 0, deltaY)
-	return
+	return cost
 }
 
-func (finder *Finder) hasForceNeighbor(prevPos, pos geo.Vec2[int64]) bool {
-	if !finder.cellMap.CanWalk(pos) {
-		return false
+func (finder *Finder) getPenalty(pos geo.Vec2[int64]) float64 {
+	var penalty float64
+
+	if finder.costMap != nil {
+		penalty += finder.costMap.GetCost(pos)
 	}
 
-	x, y := dir(pos, prevPos)
-	if x == 0 || y == 0 {
-		return finder.checkHVForceNeighbor(pos, x, y, 1) || finder.checkHVForceNeighbor(pos, x, y, -1)
-	} else {
-		return finder.checkDiagonalForceNeighbor(pos, x, y, 1) || finder.checkDiagonalForceNeighbor(pos, x, y, -1)
+	if finder.penalties != nil {
+		penalty += finder.penalties[pos]
 	}
-}
 
-func (finder *Finder) checkHVForceNeighbor(pos geo.Vec2[int64], dirX, dirY, sign int64) bool {
-	obstacle := geo.Vec2[int64]{
-		X: pos.X + math.Abs(dirX) * sign, Y: pos.Y + math.Abs(dirY) * sign,
-	} 
-	neighbor := geo.Vec2[int64]{
-		X: pos.X + dirX, Y: pos.Y + dirY,
+	if finder.penaltyFunc != nil {
+		penalty += finder.penaltyFunc(pos)
 	}
 
-	
+	if len(finder.layers) > 0 && finder.influence.contain(pos) {
+		offset := pos.Y*finder.influence.width + pos.X
+		for _, v := range finder.layers {
+			penalty += max(0, v.values[offset]*v.weight)
+		}
+	}
+
+	return penalty
 }
 
 func (finder *Finder) findDefaultNeighbors(pos geo.Vec2[int64], moveType int) []geo.Vec2[int64] {
@@ -382,22 +775,3 @@ func (finder *Finder) findNeighbors(pos geo.Vec2[int64], deltas []int64, flags [
 
 	return neighbors
 }
-
-func (finder *Finder) getMinFPos(opens map[geo.Vec2[int64]]struct{}) geo.Vec2[int64] {
-	ok := false
-	var minF float64 = 0
-	var pos geo.Vec2[int64]
-
-	for k := range opens {
-		f := finder.cellMap.GetG(k) + finder.cellMap.GetH(k)
-		if !ok || f < minF {
-			ok = true
-			minF = f
-			pos = k
-		}
-	}
-
-	delete(opens, pos)
-
-	return pos
-}
@@ -0,0 +1,314 @@
+package jps
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+var testMoves = []int{MOVE_DIAG_NEVER, MOVE_DIAG_NO_OBS, MOVE_DIAG_MOST_ONE, MOVE_DIAG_ALWAYS, MOVE_ASTAR}
+
+// randomGrid blocks about ratio of the cells of a width x height map.
+func randomGrid(rnd *rand.Rand, width, height int64, ratio float64) *GridMap {
+	grid := NewGridMap(width, height)
+
+	pos := geo.Vec2[int64]{}
+	for pos.Y = 0; pos.Y < height; pos.Y++ {
+		for pos.X = 0; pos.X < width; pos.X++ {
+			if rnd.Float64() < ratio {
+				grid.SetWalkable(pos, false)
+			}
+		}
+	}
+
+	return grid
+}
+
+func randomWalkable(rnd *rand.Rand, grid *GridMap) geo.Vec2[int64] {
+	for {
+		pos := geo.Vec2[int64]{X: rnd.Int63n(grid.Width()), Y: rnd.Int63n(grid.Height())}
+		if grid.CanWalk(pos) {
+			return pos
+		}
+	}
+}
+
+type refItem struct {
+	pos  geo.Vec2[int64]
+	cost float64
+}
+
+type refQueue []refItem
+
+func (q refQueue) Len() int           { return len(q) }
+func (q refQueue) Less(i, j int) bool { return q[i].cost < q[j].cost }
+func (q refQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *refQueue) Push(x any)        { *q = append(*q, x.(refItem)) }
+func (q *refQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// refDistances runs a plain Dijkstra over every cell step the move mode
+// allows, stepCost prices one step.
+func refDistances(grid *GridMap, start geo.Vec2[int64], move int, stepCost func(from, to geo.Vec2[int64]) float64) map[geo.Vec2[int64]]float64 {
+	dist := map[geo.Vec2[int64]]float64{start: 0}
+	queue := &refQueue{{pos: start}}
+
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(refItem)
+		if item.cost > dist[item.pos] {
+			continue
+		}
+
+		for dy := int64(-1); dy <= 1; dy++ {
+			for dx := int64(-1); dx <= 1; dx++ {
+				next := geo.Vec2[int64]{X: item.pos.X + dx, Y: item.pos.Y + dy}
+				if dx == 0 && dy == 0 || !grid.CanWalk(next) {
+					continue
+				}
+
+				if dx != 0 && dy != 0 && !canMoveDiagonal(grid.CanWalk, item.pos, dx, dy, move) {
+					continue
+				}
+
+				cost := item.cost + stepCost(item.pos, next)
+				if old, ok := dist[next]; !ok || cost < old {
+					dist[next] = cost
+					heap.Push(queue, refItem{pos: next, cost: cost})
+				}
+			}
+		}
+	}
+
+	return dist
+}
+
+// forward reverses a path, a Find path into start first order and back.
+func forward(path []geo.Vec2[int64]) []geo.Vec2[int64] {
+	path = slices.Clone(path)
+	slices.Reverse(path)
+
+	return path
+}
+
+func TestFindOptimal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	queries := 0
+
+	for round := 0; round < 40; round++ {
+		grid := randomGrid(rnd, 16+rnd.Int63n(16), 16+rnd.Int63n(16), 0.1+rnd.Float64()*0.3)
+
+		for _, move := range testMoves {
+			finder := NewFinder(grid, move)
+
+			for i := 0; i < 5; i++ {
+				start, end := randomWalkable(rnd, grid), randomWalkable(rnd, grid)
+				dist := refDistances(grid, start, move, getG)
+				want, reachable := dist[end]
+				queries++
+
+				result, err := finder.FindResult(start, end, FindOptHeuristic(HeuristicOctile))
+				if !reachable {
+					if err == nil {
+						t.Errorf("move %d %v -> %v: found a path to an unreachable end", move, start, end)
+					}
+
+					continue
+				}
+
+				if err != nil {
+					t.Errorf("move %d %v -> %v: %v, want cost %v", move, start, end, err, want)
+					continue
+				}
+
+				if err := ValidatePath(grid, start, result.Path, move); err != nil {
+					t.Errorf("move %d %v -> %v: %v", move, start, end, err)
+				}
+
+				if length := PathLength(start, result.Path); math.Abs(length-want) > 1e-9 || math.Abs(result.Cost-want) > 1e-9 {
+					t.Errorf("move %d %v -> %v: length %v cost %v, want %v", move, start, end, length, result.Cost, want)
+				}
+			}
+		}
+	}
+
+	if queries != 1000 {
+		t.Fatalf("ran %d queries", queries)
+	}
+}
+
+var fixedMaps = []string{`
+S........
+.........
+.........
+.........
+........G
+`, `
+S.........
+.#.#.#.#..
+..........
+.#.#.#.#..
+..........
+.#.#.#.#.G
+`, `
+S...#.....
+....#.##..
+.##.#..#..
+..#....#..
+..####.#..
+.......#.G
+`}
+
+// fixedGolden holds the COST_FIXED paths of fixedMaps, the open map and the
+// pillars are full of equal cost ties. A change here changes the paths games
+// replay from recorded inputs.
+var fixedGolden = []struct {
+	grid int
+	move int
+	path []geo.Vec2[int64]
+	cost float64
+}{
+	{0, MOVE_DIAG_NEVER, []geo.Vec2[int64]{{X: 8, Y: 4}, {X: 0, Y: 4}}, 12000},
+	{0, MOVE_DIAG_NO_OBS, []geo.Vec2[int64]{{X: 8, Y: 4}, {X: 4, Y: 4}}, 9656},
+	{0, MOVE_DIAG_MOST_ONE, []geo.Vec2[int64]{{X: 8, Y: 4}, {X: 4, Y: 4}}, 9656},
+	{0, MOVE_DIAG_ALWAYS, []geo.Vec2[int64]{{X: 8, Y: 4}, {X: 4, Y: 4}}, 9656},
+	{0, MOVE_ASTAR, []geo.Vec2[int64]{{X: 8, Y: 4}, {X: 7, Y: 4}, {X: 6, Y: 4}, {X: 5, Y: 4}, {X: 4, Y: 4}, {X: 3, Y: 3}, {X: 2, Y: 2}, {X: 1, Y: 1}}, 9656},
+	{1, MOVE_DIAG_NEVER, []geo.Vec2[int64]{{X: 9, Y: 5}, {X: 8, Y: 5}, {X: 8, Y: 4}, {X: 8, Y: 2}, {X: 6, Y: 2}, {X: 6, Y: 0}, {X: 4, Y: 0}, {X: 2, Y: 0}}, 14000},
+	{1, MOVE_DIAG_NO_OBS, []geo.Vec2[int64]{{X: 9, Y: 5}, {X: 8, Y: 4}, {X: 8, Y: 2}, {X: 6, Y: 2}, {X: 6, Y: 0}, {X: 4, Y: 0}, {X: 2, Y: 0}}, 13414},
+	{1, MOVE_DIAG_MOST_ONE, []geo.Vec2[int64]{{X: 9, Y: 5}, {X: 8, Y: 5}, {X: 7, Y: 4}, {X: 6, Y: 3}, {X: 5, Y: 2}, {X: 4, Y: 1}, {X: 3, Y: 0}, {X: 1, Y: 0}}, 11070},
+	{1, MOVE_DIAG_ALWAYS, []geo.Vec2[int64]{{X: 9, Y: 5}, {X: 8, Y: 5}, {X: 7, Y: 4}, {X: 6, Y: 3}, {X: 5, Y: 2}, {X: 4, Y: 1}, {X: 3, Y: 0}, {X: 1, Y: 0}}, 11070},
+	{1, MOVE_ASTAR, []geo.Vec2[int64]{{X: 9, Y: 5}, {X: 8, Y: 5}, {X: 7, Y: 4}, {X: 6, Y: 4}, {X: 5, Y: 4}, {X: 4, Y: 3}, {X: 3, Y: 2}, {X: 2, Y: 1}, {X: 1, Y: 0}}, 11070},
+	{2, MOVE_DIAG_NEVER, []geo.Vec2[int64]{{X: 9, Y: 5}, {X: 8, Y: 5}, {X: 8, Y: 0}, {X: 5, Y: 0}, {X: 5, Y: 3}, {X: 3, Y: 3}, {X: 3, Y: 1}, {X: 0, Y: 1}}, 20000},
+	{2, MOVE_DIAG_NO_OBS, []geo.Vec2[int64]{{X: 9, Y: 5}, {X: 9, Y: 1}, {X: 8, Y: 0}, {X: 5, Y: 0}, {X: 5, Y: 3}, {X: 3, Y: 3}, {X: 3, Y: 1}, {X: 1, Y: 1}}, 18828},
+	{2, MOVE_DIAG_MOST_ONE, []geo.Vec2[int64]{{X: 9, Y: 5}, {X: 9, Y: 2}, {X: 7, Y: 0}, {X: 6, Y: 0}, {X: 5, Y: 1}, {X: 5, Y: 2}, {X: 4, Y: 3}, {X: 2, Y: 1}, {X: 1, Y: 1}}, 15898},
+	{2, MOVE_DIAG_ALWAYS, []geo.Vec2[int64]{{X: 9, Y: 5}, {X: 9, Y: 2}, {X: 7, Y: 0}, {X: 6, Y: 0}, {X: 5, Y: 1}, {X: 5, Y: 2}, {X: 4, Y: 3}, {X: 2, Y: 1}, {X: 1, Y: 1}}, 15898},
+	{2, MOVE_ASTAR, []geo.Vec2[int64]{{X: 9, Y: 5}, {X: 9, Y: 4}, {X: 9, Y: 3}, {X: 9, Y: 2}, {X: 8, Y: 1}, {X: 7, Y: 0}, {X: 6, Y: 0}, {X: 5, Y: 1}, {X: 5, Y: 2}, {X: 4, Y: 3}, {X: 3, Y: 2}, {X: 2, Y: 1}, {X: 1, Y: 1}}, 15898},
+}
+
+func TestFixedGolden(t *testing.T) {
+	for _, v := range fixedGolden {
+		grid, markers, err := ParseGridMap(fixedMaps[v.grid])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		finder := NewFinder(grid, v.move)
+		finder.SetCostMode(COST_FIXED)
+
+		result, err := finder.FindResult(markers.Start, markers.Goal)
+		if err != nil {
+			t.Errorf("map %d move %d: %v", v.grid, v.move, err)
+			continue
+		}
+
+		if !slices.Equal(result.Path, v.path) || result.Cost != v.cost {
+			t.Errorf("map %d move %d: got %v cost %v, want %v cost %v", v.grid, v.move, result.Path, result.Cost, v.path, v.cost)
+		}
+
+		// the golden costs are optimal, not only recorded
+		dist := refDistances(grid, markers.Start, v.move, getFixedG)
+		if dist[markers.Goal] != v.cost {
+			t.Errorf("map %d move %d: golden cost %v, optimal %v", v.grid, v.move, v.cost, dist[markers.Goal])
+		}
+	}
+}
+
+type fixedQuery struct {
+	start geo.Vec2[int64]
+	end   geo.Vec2[int64]
+}
+
+func fixedQueries(rnd *rand.Rand, grid *GridMap, count int) []fixedQuery {
+	queries := make([]fixedQuery, count)
+	for i := range queries {
+		queries[i] = fixedQuery{start: randomWalkable(rnd, grid), end: randomWalkable(rnd, grid)}
+	}
+
+	return queries
+}
+
+func TestFixedRepeat(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	grid := randomGrid(rnd, 32, 32, 0.25)
+	queries := fixedQueries(rnd, grid, 60)
+
+	for _, move := range testMoves {
+		finder := NewFinder(grid, move)
+		finder.SetCostMode(COST_FIXED)
+
+		first := make([]Result[geo.Vec2[int64]], len(queries))
+		for i, v := range queries {
+			first[i], _ = finder.FindResult(v.start, v.end)
+		}
+
+		// the same finder again in the other order, scratch left by earlier
+		// queries must not change a result
+		for round := 0; round < 3; round++ {
+			for i := len(queries) - 1; i >= 0; i-- {
+				result, _ := finder.FindResult(queries[i].start, queries[i].end)
+				if !slices.Equal(result.Path, first[i].Path) || result.Cost != first[i].Cost {
+					t.Fatalf("move %d query %d round %d: got %v cost %v, first run %v cost %v",
+						move, i, round, result.Path, result.Cost, first[i].Path, first[i].Cost)
+				}
+			}
+		}
+	}
+}
+
+func TestFixedCost(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+
+	for round := 0; round < 10; round++ {
+		grid := randomGrid(rnd, 24+rnd.Int63n(16), 24+rnd.Int63n(16), 0.3)
+
+		for _, move := range testMoves {
+			finder := NewFinder(grid, move)
+			finder.SetCostMode(COST_FIXED)
+
+			for _, v := range fixedQueries(rnd, grid, 10) {
+				result, err := finder.FindResult(v.start, v.end)
+				if err != nil {
+					continue
+				}
+
+				var cost int64
+				prev := v.start
+				for _, pos := range forward(result.Path) {
+					cost += getFixedOctile(pos, prev)
+					prev = pos
+				}
+
+				if result.Cost != float64(cost) {
+					t.Errorf("move %d %v -> %v: cost %v, path sums to %d", move, v.start, v.end, result.Cost, cost)
+				}
+			}
+		}
+	}
+}
+
+func TestReversePathPerQuery(t *testing.T) {
+	grid, markers, err := ParseGridMap(fixedMaps[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finder := NewFinder(grid, MOVE_DIAG_NO_OBS)
+
+	path, err := finder.FindPath(markers.Start, markers.Goal, FindOptReversePath(true))
+	if err != nil || path[len(path)-1] != markers.Goal {
+		t.Fatalf("reversed: %v %v, want %v last", path, err, markers.Goal)
+	}
+
+	// the next query without the option returns end first again
+	path, err = finder.FindPath(markers.Start, markers.Goal)
+	if err != nil || path[0] != markers.Goal {
+		t.Fatalf("default: %v %v, want %v first", path, err, markers.Goal)
+	}
+}
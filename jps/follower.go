@@ -0,0 +1,183 @@
+package jps
+
+import (
+	"math"
+	"slices"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+// PathFollower moves a unit along a Finder path in world space. Cell c maps
+// to c * cellSize + offset, the offset defaults to the cell centre.
+type PathFollower struct {
+	cellMap   CellMap
+	cellSize  float64
+	offset    geo.Vec2[float64]
+	speed     float64
+	lookAhead float64
+
+	path    []geo.Vec2[int64]
+	index   int
+	pos     geo.Vec2[float64]
+	heading geo.Vec2[float64]
+	replan  bool
+	reached []geo.Vec2[int64]
+}
+
+func NewPathFollower(cellSize, speed float64) *PathFollower {
+	follower := new(PathFollower)
+	follower.cellSize = cellSize
+	follower.offset = geo.Vec2[float64]{X: cellSize / 2, Y: cellSize / 2}
+	follower.speed = speed
+
+	return follower
+}
+
+func (follower *PathFollower) SetOffset(offset geo.Vec2[float64]) {
+	follower.offset = offset
+}
+
+func (follower *PathFollower) SetSpeed(speed float64) {
+	follower.speed = speed
+}
+
+// SetLookAhead lets the unit turn to the next waypoint once it is within
+// distance of the current one, which rounds the corners. Keep it below half
+// a cell when the path passes next to walls.
+func (follower *PathFollower) SetLookAhead(distance float64) {
+	follower.lookAhead = distance
+}
+
+// SetCellMap makes the follower stop and ask for a replan when a cell of the
+// segments ahead becomes blocked.
+func (follower *PathFollower) SetCellMap(cellMap CellMap) {
+	follower.cellMap = cellMap
+}
+
+func (follower *PathFollower) CellToWorld(cell geo.Vec2[int64]) geo.Vec2[float64] {
+	return geo.Vec2[float64]{
+		X: float64(cell.X)*follower.cellSize + follower.offset.X,
+		Y: float64(cell.Y)*follower.cellSize + follower.offset.Y,
+	}
+}
+
+func (follower *PathFollower) WorldToCell(pos geo.Vec2[float64]) geo.Vec2[int64] {
+	return geo.Vec2[int64]{
+		X: int64(math.Floor((pos.X - follower.offset.X + follower.cellSize/2) / follower.cellSize)),
+		Y: int64(math.Floor((pos.Y - follower.offset.Y + follower.cellSize/2) / follower.cellSize)),
+	}
+}
+
+// SetPath starts following path from the world position pos. path is in the
+// order Find returns by default, end first, and is copied.
+func (follower *PathFollower) SetPath(pos geo.Vec2[float64], path []geo.Vec2[int64]) {
+	follower.path = append(follower.path[:0], path...)
+	slices.Reverse(follower.path)
+	follower.index = 0
+	follower.pos = pos
+	follower.heading = geo.Vec2[float64]{}
+	follower.replan = false
+}
+
+func (follower *PathFollower) Pos() geo.Vec2[float64] {
+	return follower.pos
+}
+
+// Heading is the unit direction of the last move, zero before moving.
+func (follower *PathFollower) Heading() geo.Vec2[float64] {
+	return follower.heading
+}
+
+// Next returns the waypoint the unit is walking to.
+func (follower *PathFollower) Next() (geo.Vec2[int64], bool) {
+	if follower.Done() {
+		return geo.Vec2[int64]{}, false
+	}
+
+	return follower.path[follower.index], true
+}
+
+func (follower *PathFollower) Done() bool {
+	return follower.index >= len(follower.path)
+}
+
+// NeedReplan reports that a blocked cell stopped the last Advance, search
+// again from Pos and call SetPath.
+func (follower *PathFollower) NeedReplan() bool {
+	return follower.replan
+}
+
+// Advance moves the unit by speed * dt and returns the waypoints reached on
+// the way, the slice is reused by the next call.
+func (follower *PathFollower) Advance(dt float64) []geo.Vec2[int64] {
+	follower.reached = follower.reached[:0]
+	follower.replan = false
+	distance := follower.speed * dt
+
+	for !follower.Done() {
+		if follower.isBlocked() {
+			follower.replan = true
+			break
+		}
+
+		target := follower.CellToWorld(follower.path[follower.index])
+		delta := target.Sub(follower.pos)
+		length := delta.Len()
+
+		if length <= distance || follower.index+1 < len(follower.path) && length <= follower.lookAhead {
+			if length <= distance {
+				follower.move(delta, length, length)
+				distance -= length
+			}
+
+			follower.reached = append(follower.reached, follower.path[follower.index])
+			follower.index++
+			continue
+		}
+
+		if distance <= 0 {
+			break
+		}
+
+		follower.move(delta, length, distance)
+		break
+	}
+
+	return follower.reached
+}
+
+func (follower *PathFollower) move(delta geo.Vec2[float64], length, distance float64) {
+	if length == 0 {
+		return
+	}
+
+	follower.heading = geo.Vec2[float64]{X: delta.X / length, Y: delta.Y / length}
+	follower.pos.X += follower.heading.X * distance
+	follower.pos.Y += follower.heading.Y * distance
+}
+
+// isBlocked checks the cells from the one of the unit to the current waypoint
+// and on to the next one, the cells already passed do not matter.
+func (follower *PathFollower) isBlocked() bool {
+	if follower.cellMap == nil {
+		return false
+	}
+
+	for i := follower.index; i < len(follower.path) && i <= follower.index+1; i++ {
+		prev := follower.WorldToCell(follower.pos)
+		if i > follower.index {
+			prev = follower.path[i-1]
+		}
+
+		for cell := prev; cell != follower.path[i]; {
+			dx, dy := dir(follower.path[i], cell)
+			cell.X += dx
+			cell.Y += dy
+			if !follower.cellMap.CanWalk(cell) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,31 @@
+package jps
+
+import (
+	"testing"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+func TestFollowerReplan(t *testing.T) {
+	grid := NewGridMap(12, 3)
+	follower := NewPathFollower(1, 1)
+	follower.SetCellMap(grid)
+
+	// end first, like Find returns it
+	follower.SetPath(follower.CellToWorld(geo.Vec2[int64]{X: 0, Y: 1}), []geo.Vec2[int64]{{X: 11, Y: 1}, {X: 6, Y: 1}})
+	follower.Advance(4)
+
+	// a cell the unit walked past does not stop it
+	grid.SetWalkable(geo.Vec2[int64]{X: 2, Y: 1}, false)
+	follower.Advance(1)
+	if follower.NeedReplan() {
+		t.Fatalf("replan for a cell behind the unit at %v", follower.Pos())
+	}
+
+	// a cell on the segment after the current waypoint does
+	grid.SetWalkable(geo.Vec2[int64]{X: 9, Y: 1}, false)
+	follower.Advance(1)
+	if !follower.NeedReplan() {
+		t.Fatalf("no replan for a cell ahead of the unit at %v", follower.Pos())
+	}
+}
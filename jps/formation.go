@@ -0,0 +1,242 @@
+package jps
+
+import (
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+const (
+	FORMATION_LINE = iota
+	FORMATION_WEDGE
+	FORMATION_BOX
+)
+
+// GroupPlan is the result of FindGroup. Slots, Paths and Errs follow the
+// order of the members, a member whose Errs entry is set has no path. The
+// paths are ordered like Find returns them.
+type GroupPlan struct {
+	Leader []geo.Vec2[int64]
+	Slots  []geo.Vec2[int64]
+	Paths  [][]geo.Vec2[int64]
+	Errs   []error
+}
+
+// FormationSlots places count members in shape behind or beside a leader at
+// anchor facing heading, spacing cells apart. The slots may be blocked, use
+// it to lay the formation along any waypoint of the leader path.
+func FormationSlots(anchor, heading geo.Vec2[int64], shape, count int, spacing int64) []geo.Vec2[int64] {
+	fx, fy := dir(heading, geo.Vec2[int64]{})
+	if fx == 0 && fy == 0 {
+		fy = -1
+	}
+
+	// right hand side of the heading
+	rx, ry := -fy, fx
+
+	slot := func(back, right int64) geo.Vec2[int64] {
+		return geo.Vec2[int64]{
+			X: anchor.X + (rx*right-fx*back)*spacing,
+			Y: anchor.Y + (ry*right-fy*back)*spacing,
+		}
+	}
+
+	slots := make([]geo.Vec2[int64], 0, count)
+	switch shape {
+	case FORMATION_LINE, FORMATION_WEDGE:
+		for i := 0; i < count; i++ {
+			side := int64(i/2 + 1)
+			right := side
+			if i%2 == 1 {
+				right = -side
+			}
+
+			back := int64(0)
+			if shape == FORMATION_WEDGE {
+				back = side
+			}
+
+			slots = append(slots, slot(back, right))
+		}
+
+	case FORMATION_BOX:
+		// rows of side slots, the leader takes the middle of the front row
+		side := int(math.Ceil(math.Sqrt(float64(count + 1))))
+		for i := 1; i <= count; i++ {
+			column := int64(i%side+1) / 2
+			if i%side%2 == 1 {
+				column = -column
+			}
+
+			slots = append(slots, slot(int64(i/side), column))
+		}
+	}
+
+	return slots
+}
+
+// FindGroup finds the path of the leader to end, lays the formation around
+// the cell that path reaches facing its last step and finds a path for every
+// member to its slot. The reached cell is end unless FindOptNearest stopped
+// the leader short of it. A slot that is blocked, taken or cut off from that
+// cell moves to the nearest free cell connected to it, members take the slots
+// nearest to them.
+// An error is only returned when the leader has no path.
+func (finder *Finder) FindGroup(leader geo.Vec2[int64], members []geo.Vec2[int64], end geo.Vec2[int64], shape int, spacing int64, options ...FindOption) (*GroupPlan, error) {
+	finder.applyOptions(options)
+
+	plan := &GroupPlan{
+		Slots: make([]geo.Vec2[int64], len(members)),
+		Paths: make([][]geo.Vec2[int64], len(members)),
+		Errs:  make([]error, len(members)),
+	}
+
+	leaderPath, err := finder.search(leader, end, make([]geo.Vec2[int64], 0))
+	if err != nil {
+		return nil, err
+	}
+
+	// the path is end first, with FindOptNearest it may stop short of end
+	anchor := leader
+	if len(leaderPath) > 0 {
+		anchor = leaderPath[0]
+	}
+
+	heading := end.Sub(leader)
+	if len(leaderPath) > 1 {
+		heading = anchor.Sub(leaderPath[1])
+	} else if len(leaderPath) == 1 {
+		heading = anchor.Sub(leader)
+	}
+
+	plan.Leader = finder.orderPath(leaderPath)
+
+	spacing = max(spacing, 1)
+	wanted := FormationSlots(anchor, heading, shape, len(members), spacing)
+	slots := finder.placeSlots(anchor, wanted, spacing*int64(len(members)+1))
+
+	for i, v := range finder.assignSlots(members, slots) {
+		if v < 0 {
+			plan.Slots[i] = members[i]
+			plan.Errs[i] = ErrUnreachable
+			continue
+		}
+
+		plan.Slots[i] = slots[v]
+		path, err := finder.search(members[i], slots[v], make([]geo.Vec2[int64], 0))
+		if err != nil {
+			plan.Errs[i] = err
+			continue
+		}
+
+		plan.Paths[i] = finder.orderPath(path)
+	}
+
+	return plan, nil
+}
+
+func (finder *Finder) orderPath(list []geo.Vec2[int64]) []geo.Vec2[int64] {
+	if finder.reversePath {
+		slices.Reverse(list)
+	}
+
+	return list
+}
+
+// placeSlots keeps the wanted slots that are free and connected to anchor
+// within radius cells and moves the others to the nearest such cell. A slot
+// with no cell left is dropped from the result.
+func (finder *Finder) placeSlots(anchor geo.Vec2[int64], wanted []geo.Vec2[int64], radius int64) []geo.Vec2[int64] {
+	finder.startPos = anchor
+	if !finder.canWalk(anchor) {
+		return nil
+	}
+
+	reach := []geo.Vec2[int64]{anchor}
+	reached := map[geo.Vec2[int64]]bool{anchor: true}
+
+	for i := 0; i < len(reach); i++ {
+		pos := reach[i]
+		for dy := int64(-1); dy <= 1; dy++ {
+			for dx := int64(-1); dx <= 1; dx++ {
+				next := geo.Vec2[int64]{X: pos.X + dx, Y: pos.Y + dy}
+				if reached[next] || max(abs(next.X-anchor.X), abs(next.Y-anchor.Y)) > radius ||
+					!finder.canStep(pos, next) {
+					continue
+				}
+
+				reached[next] = true
+				reach = append(reach, next)
+			}
+		}
+	}
+
+	// the leader stands on anchor
+	taken := map[geo.Vec2[int64]]bool{anchor: true}
+	slots := make([]geo.Vec2[int64], 0, len(wanted))
+
+	for _, v := range wanted {
+		if reached[v] && !taken[v] {
+			taken[v] = true
+			slots = append(slots, v)
+			continue
+		}
+
+		best, found := geo.Vec2[int64]{}, false
+		var bestDistance int64
+		for _, cell := range reach {
+			if taken[cell] {
+				continue
+			}
+
+			if distance := cell.Sub(v).LenSqr(); !found || distance < bestDistance {
+				best, bestDistance, found = cell, distance, true
+			}
+		}
+
+		if found {
+			taken[best] = true
+			slots = append(slots, best)
+		}
+	}
+
+	return slots
+}
+
+// assignSlots gives every member the index of its slot, -1 when there are
+// fewer slots than members. The closest member and slot pair goes first.
+func (finder *Finder) assignSlots(members, slots []geo.Vec2[int64]) []int {
+	type pair struct {
+		member   int
+		slot     int
+		distance float64
+	}
+
+	pairs := make([]pair, 0, len(members)*len(slots))
+	for i, member := range members {
+		for j, slot := range slots {
+			pairs = append(pairs, pair{member: i, slot: j, distance: HeuristicOctile(member, slot)})
+		}
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].distance < pairs[j].distance
+	})
+
+	assigned := make([]int, len(members))
+	for i := range assigned {
+		assigned[i] = -1
+	}
+
+	used := make([]bool, len(slots))
+	for _, v := range pairs {
+		if assigned[v.member] < 0 && !used[v.slot] {
+			assigned[v.member] = v.slot
+			used[v.slot] = true
+		}
+	}
+
+	return assigned
+}
@@ -0,0 +1,197 @@
+package jps
+
+import (
+	"slices"
+)
+
+// Edge leads to Node for Cost, costs must not be negative.
+type Edge[N comparable] struct {
+	Node N
+	Cost float64
+}
+
+// Graph is what GraphFinder searches. Neighbors appends the edges leaving
+// node to list, Heuristic estimates the cost from node to goal. A closed node
+// is never opened again, so for the path to be the cheapest the heuristic
+// must be consistent: never above the cost of an edge plus the estimate from
+// the node it leads to, and zero at goal.
+type Graph[N comparable] interface {
+	Neighbors(node N, list []Edge[N]) []Edge[N]
+	Heuristic(node, goal N) float64
+}
+
+type graphNode[N comparable] struct {
+	parent N
+	g      float64
+	h      float64
+	closed bool
+}
+
+// GraphFinder runs A* over a Graph with the open list and budget of Finder.
+// Like Finder it keeps its scratch data between queries and is not safe for
+// concurrent use.
+type GraphFinder[N comparable] struct {
+	graph  Graph[N]
+	nodes  map[N]*graphNode[N]
+	opens  openList[N]
+	budget searchBudget
+	edges  []Edge[N]
+	end    N
+
+	budgetLimit int
+	dijkstra    bool
+	reversePath bool
+	observer    func(node N, g float64)
+}
+
+type GraphOption[N comparable] func(finder *GraphFinder[N])
+
+func NewGraphFinder[N comparable](graph Graph[N]) *GraphFinder[N] {
+	finder := new(GraphFinder[N])
+	finder.graph = graph
+	finder.nodes = make(map[N]*graphNode[N])
+
+	return finder
+}
+
+// GraphOptBudget stops the query after expanding nodes nodes with ErrBudget.
+func GraphOptBudget[N comparable](nodes int) GraphOption[N] {
+	return func(finder *GraphFinder[N]) {
+		finder.budgetLimit = nodes
+	}
+}
+
+// GraphOptDijkstra ignores the graph heuristic.
+func GraphOptDijkstra[N comparable](dijkstra bool) GraphOption[N] {
+	return func(finder *GraphFinder[N]) {
+		finder.dijkstra = dijkstra
+	}
+}
+
+// GraphOptReversePath returns the path from start (excluded) to end instead of
+// end first, like FindOptReversePath.
+func GraphOptReversePath[N comparable](reverse bool) GraphOption[N] {
+	return func(finder *GraphFinder[N]) {
+		finder.reversePath = reverse
+	}
+}
+
+// GraphOptObserver calls observer for every expanded node.
+func GraphOptObserver[N comparable](observer func(node N, g float64)) GraphOption[N] {
+	return func(finder *GraphFinder[N]) {
+		finder.observer = observer
+	}
+}
+
+// FindResult searches from start to end, the path runs from end back to start
+// (excluded) like the one of Finder.FindResult.
+func (finder *GraphFinder[N]) FindResult(start, end N, options ...GraphOption[N]) (Result[N], error) {
+	finder.budgetLimit = 0
+	finder.dijkstra = false
+	finder.reversePath = false
+	finder.observer = nil
+
+	for _, v := range options {
+		v(finder)
+	}
+
+	result := Result[N]{}
+	found, err := finder.run(start, end, true, nil)
+	result.Expanded = finder.budget.expanded
+	if !found {
+		clear(finder.nodes)
+		return result, err
+	}
+
+	result.Cost = finder.nodes[end].g
+	for node := end; node != start; node = finder.nodes[node].parent {
+		result.Path = append(result.Path, node)
+	}
+
+	if finder.reversePath {
+		slices.Reverse(result.Path)
+	}
+
+	clear(finder.nodes)
+
+	return result, nil
+}
+
+// run expands nodes from start until end is closed, or until the open list
+// is empty when stop is false, visit sees every closed node and ends the run
+// by returning false.
+func (finder *GraphFinder[N]) run(start, end N, stop bool, visit func(node N, g float64) bool) (bool, error) {
+	clear(finder.nodes)
+	finder.budget.reset(finder.budgetLimit)
+	finder.end = end
+
+	found := false
+	finder.nodes[start] = &graphNode[N]{h: finder.getH(start, end)}
+	err := searchLoop[N](finder, &finder.opens, &finder.budget, start, finder.nodes[start].h, func(node N, g float64) bool {
+		if finder.observer != nil {
+			finder.observer(node, g)
+		}
+
+		if visit != nil && !visit(node, g) {
+			return false
+		}
+
+		if stop && node == end {
+			found = true
+			return false
+		}
+
+		return true
+	})
+
+	return found, err
+}
+
+func (finder *GraphFinder[N]) isClosed(node N) bool {
+	return finder.nodes[node].closed
+}
+
+func (finder *GraphFinder[N]) closeNode(node N) float64 {
+	info := finder.nodes[node]
+	info.closed = true
+
+	return info.g
+}
+
+func (finder *GraphFinder[N]) expandNode(node N, g float64) {
+	finder.edges = finder.graph.Neighbors(node, finder.edges[:0])
+	for _, v := range finder.edges {
+		newG := g + v.Cost
+
+		next, ok := finder.nodes[v.Node]
+		if !ok {
+			next = &graphNode[N]{h: finder.getH(v.Node, finder.end)}
+			finder.nodes[v.Node] = next
+		} else if next.closed || newG >= next.g {
+			continue
+		}
+
+		next.g = newG
+		next.parent = node
+		finder.opens.Push(v.Node, newG, next.h)
+	}
+}
+
+// distances runs Dijkstra from start over the reachable nodes in increasing
+// distance until visit returns false.
+func (finder *GraphFinder[N]) distances(start N, visit func(node N, g float64) bool) {
+	finder.budgetLimit = 0
+	finder.dijkstra = true
+	finder.observer = nil
+
+	finder.run(start, start, false, visit)
+	clear(finder.nodes)
+}
+
+func (finder *GraphFinder[N]) getH(node, end N) float64 {
+	if finder.dijkstra {
+		return 0
+	}
+
+	return finder.graph.Heuristic(node, end)
+}
@@ -0,0 +1,52 @@
+package jps
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+func TestGraphFinderOrder(t *testing.T) {
+	rnd := rand.New(rand.NewSource(10))
+
+	for round := 0; round < 20; round++ {
+		grid := randomGrid(rnd, 24, 24, 0.25)
+		graph := &gridGraph{cellMap: grid, move: MOVE_DIAG_NO_OBS, width: grid.Width(), height: grid.Height()}
+		finder := NewGraphFinder[geo.Vec2[int64]](graph)
+
+		for i := 0; i < 5; i++ {
+			start, end := randomWalkable(rnd, grid), randomWalkable(rnd, grid)
+			want, reachable := refDistances(grid, start, MOVE_DIAG_NO_OBS, getG)[end]
+
+			result, err := finder.FindResult(start, end)
+			if !reachable {
+				if err == nil {
+					t.Errorf("%v -> %v: found a path to an unreachable end", start, end)
+				}
+
+				continue
+			}
+
+			if err != nil || start != end && result.Path[0] != end {
+				t.Fatalf("%v -> %v: %v %v, want end first", start, end, result.Path, err)
+			}
+
+			// end first like Find, so the path utilities take it as is
+			if err := ValidatePath(grid, start, result.Path, MOVE_DIAG_NO_OBS); err != nil {
+				t.Errorf("%v -> %v: %v", start, end, err)
+			}
+
+			if math.Abs(result.Cost-want) > 1e-9 {
+				t.Errorf("%v -> %v: cost %v, want %v", start, end, result.Cost, want)
+			}
+
+			reversed, _ := finder.FindResult(start, end, GraphOptReversePath[geo.Vec2[int64]](true))
+			if !slices.Equal(reversed.Path, forward(result.Path)) {
+				t.Errorf("%v -> %v: reversed %v, want %v", start, end, reversed.Path, forward(result.Path))
+			}
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package jps
+
+import "github.com/xtxy/cxlib/geo"
+
+// GridMap is a CellMap over the cells [0, width) x [0, height), every cell
+// outside is blocked. Reset only clears the cells touched by the last search.
+// The terrain flags of a cell are the capabilities a unit needs to enter it,
+// see CapabilityCellMap.
+type GridMap struct {
+	walkable []bool
+	costs    []float64
+	terrains []uint64
+
+	gridSearch
+}
+
+const (
+	grid_flag_touched = 1 << iota
+	grid_flag_parent
+)
+
+func NewGridMap(width, height int64) *GridMap {
+	grid := new(GridMap)
+	grid.walkable = make([]bool, width*height)
+	for i := range grid.walkable {
+		grid.walkable[i] = true
+	}
+
+	grid.gridSearch = newGridSearch(width, height)
+
+	return grid
+}
+
+// Share returns a GridMap with its own search data over the same cells, costs
+// and terrain, so several finders can search one map at once. Finish setting
+// the map up before sharing it and do not change it while searches run.
+func (grid *GridMap) Share() *GridMap {
+	share := &GridMap{
+		walkable:   grid.walkable,
+		costs:      grid.costs,
+		terrains:   grid.terrains,
+		gridSearch: newGridSearch(grid.width, grid.height),
+	}
+
+	return share
+}
+
+func (grid *GridMap) SetWalkable(pos geo.Vec2[int64], walkable bool) {
+	if grid.Contain(pos) {
+		grid.walkable[grid.offset(pos)] = walkable
+	}
+}
+
+func (grid *GridMap) CanWalk(pos geo.Vec2[int64]) bool {
+	return grid.CanWalkWith(pos, 0)
+}
+
+func (grid *GridMap) CanWalkWith(pos geo.Vec2[int64], caps uint64) bool {
+	if !grid.Contain(pos) {
+		return false
+	}
+
+	offset := grid.offset(pos)
+	if !grid.walkable[offset] {
+		return false
+	}
+
+	return grid.terrains == nil || grid.terrains[offset]&^caps == 0
+}
+
+func (grid *GridMap) SetTerrain(pos geo.Vec2[int64], flags uint64) {
+	if !grid.Contain(pos) {
+		return
+	}
+
+	if grid.terrains == nil {
+		if flags == 0 {
+			return
+		}
+
+		grid.terrains = make([]uint64, len(grid.walkable))
+	}
+
+	grid.terrains[grid.offset(pos)] = flags
+}
+
+func (grid *GridMap) GetTerrain(pos geo.Vec2[int64]) uint64 {
+	if grid.terrains == nil || !grid.Contain(pos) {
+		return 0
+	}
+
+	return grid.terrains[grid.offset(pos)]
+}
+
+// SetCost adds an extra cost for entering pos, see CostCellMap.
+func (grid *GridMap) SetCost(pos geo.Vec2[int64], cost float64) {
+	if !grid.Contain(pos) {
+		return
+	}
+
+	if grid.costs == nil {
+		if cost == 0 {
+			return
+		}
+
+		grid.costs = make([]float64, len(grid.walkable))
+	}
+
+	grid.costs[grid.offset(pos)] = cost
+}
+
+func (grid *GridMap) GetCost(pos geo.Vec2[int64]) float64 {
+	if grid.costs == nil || !grid.Contain(pos) {
+		return 0
+	}
+
+	return grid.costs[grid.offset(pos)]
+}
+
+// gridSearch is the search data of a CellMap over [0, width) x [0, height).
+type gridSearch struct {
+	width  int64
+	height int64
+
+	parents []geo.Vec2[int64]
+	states  []uint8
+	flags   []uint8
+	gs      []float64
+	hs      []float64
+	touched []int64
+}
+
+func newGridSearch(width, height int64) gridSearch {
+	size := width * height
+
+	return gridSearch{
+		width:   width,
+		height:  height,
+		parents: make([]geo.Vec2[int64], size),
+		states:  make([]uint8, size),
+		flags:   make([]uint8, size),
+		gs:      make([]float64, size),
+		hs:      make([]float64, size),
+	}
+}
+
+func (grid *gridSearch) Width() int64 {
+	return grid.width
+}
+
+func (grid *gridSearch) Height() int64 {
+	return grid.height
+}
+
+func (grid *gridSearch) Contain(pos geo.Vec2[int64]) bool {
+	return pos.X >= 0 && pos.Y >= 0 && pos.X < grid.width && pos.Y < grid.height
+}
+
+func (grid *gridSearch) Reset() {
+	for _, v := range grid.touched {
+		grid.states[v] = CELL_STATE_NORMAL
+		grid.flags[v] = 0
+		grid.gs[v] = 0
+		grid.hs[v] = 0
+	}
+
+	grid.touched = grid.touched[:0]
+}
+
+func (grid *gridSearch) SetParent(pos, parent geo.Vec2[int64]) {
+	if offset, ok := grid.touch(pos); ok {
+		grid.parents[offset] = parent
+		grid.flags[offset] |= grid_flag_parent
+	}
+}
+
+func (grid *gridSearch) GetParent(pos geo.Vec2[int64]) (geo.Vec2[int64], bool) {
+	if !grid.Contain(pos) {
+		return geo.Vec2[int64]{}, false
+	}
+
+	offset := grid.offset(pos)
+	return grid.parents[offset], grid.flags[offset]&grid_flag_parent != 0
+}
+
+func (grid *gridSearch) SetState(pos geo.Vec2[int64], state uint8) {
+	if offset, ok := grid.touch(pos); ok {
+		grid.states[offset] = state
+	}
+}
+
+func (grid *gridSearch) GetState(pos geo.Vec2[int64]) uint8 {
+	if !grid.Contain(pos) {
+		return CELL_STATE_NORMAL
+	}
+
+	return grid.states[grid.offset(pos)]
+}
+
+func (grid *gridSearch) SetG(pos geo.Vec2[int64], value float64) {
+	if offset, ok := grid.touch(pos); ok {
+		grid.gs[offset] = value
+	}
+}
+
+func (grid *gridSearch) GetG(pos geo.Vec2[int64]) float64 {
+	if !grid.Contain(pos) {
+		return 0
+	}
+
+	return grid.gs[grid.offset(pos)]
+}
+
+func (grid *gridSearch) SetH(pos geo.Vec2[int64], value float64) {
+	if offset, ok := grid.touch(pos); ok {
+		grid.hs[offset] = value
+	}
+}
+
+func (grid *gridSearch) GetH(pos geo.Vec2[int64]) float64 {
+	if !grid.Contain(pos) {
+		return 0
+	}
+
+	return grid.hs[grid.offset(pos)]
+}
+
+func (grid *gridSearch) touch(pos geo.Vec2[int64]) (int64, bool) {
+	if !grid.Contain(pos) {
+		return 0, false
+	}
+
+	offset := grid.offset(pos)
+	if grid.flags[offset]&grid_flag_touched == 0 {
+		grid.flags[offset] |= grid_flag_touched
+		grid.touched = append(grid.touched, offset)
+	}
+
+	return offset, true
+}
+
+func (grid *gridSearch) offset(pos geo.Vec2[int64]) int64 {
+	return pos.Y*grid.width + pos.X
+}
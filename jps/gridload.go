@@ -0,0 +1,158 @@
+package jps
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+type imageOptions struct {
+	threshold uint8
+	terrain   func(c color.Color) (walkable bool, cost float64)
+	costLayer image.Image
+	costScale float64
+}
+
+type ImageOption func(opt *imageOptions)
+
+// ImageOptThreshold sets the grey level from which a pixel is walkable,
+// 128 by default.
+func ImageOptThreshold(threshold uint8) ImageOption {
+	return func(opt *imageOptions) {
+		opt.threshold = threshold
+	}
+}
+
+// ImageOptTerrain maps every pixel to its walkability and cost itself,
+// the threshold is ignored.
+func ImageOptTerrain(terrain func(c color.Color) (walkable bool, cost float64)) ImageOption {
+	return func(opt *imageOptions) {
+		opt.terrain = terrain
+	}
+}
+
+// ImageOptCostLayer adds grey level / 255 * scale of the matching layer pixel
+// to the cost of every cell.
+func ImageOptCostLayer(layer image.Image, scale float64) ImageOption {
+	return func(opt *imageOptions) {
+		opt.costLayer = layer
+		opt.costScale = scale
+	}
+}
+
+// NewGridMapFromImage builds a GridMap with one cell per pixel, the top left
+// pixel of the image bounds is cell (0, 0).
+func NewGridMapFromImage(img image.Image, options ...ImageOption) *GridMap {
+	opt := imageOptions{threshold: 128}
+	for _, v := range options {
+		v(&opt)
+	}
+
+	bounds := img.Bounds()
+	grid := NewGridMap(int64(bounds.Dx()), int64(bounds.Dy()))
+	pos := geo.Vec2[int64]{}
+
+	for pos.Y = 0; pos.Y < grid.height; pos.Y++ {
+		for pos.X = 0; pos.X < grid.width; pos.X++ {
+			c := img.At(bounds.Min.X+int(pos.X), bounds.Min.Y+int(pos.Y))
+
+			walkable, cost := true, 0.0
+			if opt.terrain != nil {
+				walkable, cost = opt.terrain(c)
+			} else {
+				walkable = color.GrayModel.Convert(c).(color.Gray).Y >= opt.threshold
+			}
+
+			if opt.costLayer != nil {
+				layerBounds := opt.costLayer.Bounds()
+				layerPos := image.Point{X: layerBounds.Min.X + int(pos.X), Y: layerBounds.Min.Y + int(pos.Y)}
+				if layerPos.In(layerBounds) {
+					grey := color.GrayModel.Convert(opt.costLayer.At(layerPos.X, layerPos.Y)).(color.Gray).Y
+					cost += float64(grey) / 255 * opt.costScale
+				}
+			}
+
+			grid.SetWalkable(pos, walkable)
+			grid.SetCost(pos, cost)
+		}
+	}
+
+	return grid
+}
+
+type GridMarkers struct {
+	Start    geo.Vec2[int64]
+	Goal     geo.Vec2[int64]
+	HasStart bool
+	HasGoal  bool
+}
+
+// ParseGridMap builds a GridMap from a text sketch: '.' or ' ' is walkable,
+// '#' is blocked, '1' to '9' are walkable with that cost, 'S' and 'G' are the
+// walkable start and goal. Leading and trailing empty lines are skipped and
+// short rows are padded with blocked cells.
+func ParseGridMap(text string) (*GridMap, GridMarkers, error) {
+	markers := GridMarkers{}
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var width int64
+	for _, v := range lines {
+		width = max(width, int64(len(v)))
+	}
+
+	grid := NewGridMap(width, int64(len(lines)))
+	pos := geo.Vec2[int64]{}
+
+	for y, line := range lines {
+		pos.Y = int64(y)
+
+		for pos.X = 0; pos.X < width; pos.X++ {
+			if pos.X >= int64(len(line)) {
+				grid.SetWalkable(pos, false)
+				continue
+			}
+
+			switch c := line[pos.X]; {
+			case c == '.' || c == ' ':
+
+			case c == '#':
+				grid.SetWalkable(pos, false)
+
+			case c >= '1' && c <= '9':
+				grid.SetCost(pos, float64(c-'0'))
+
+			case c == 'S':
+				if markers.HasStart {
+					return nil, markers, fmt.Errorf("jps: second start marker at %v", pos)
+				}
+
+				markers.Start = pos
+				markers.HasStart = true
+
+			case c == 'G':
+				if markers.HasGoal {
+					return nil, markers, fmt.Errorf("jps: second goal marker at %v", pos)
+				}
+
+				markers.Goal = pos
+				markers.HasGoal = true
+
+			default:
+				return nil, markers, fmt.Errorf("jps: unknown map character %q at %v", c, pos)
+			}
+		}
+	}
+
+	return grid, markers, nil
+}
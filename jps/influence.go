@@ -0,0 +1,157 @@
+package jps
+
+import (
+	"math"
+
+	"github.com/xtxy/cxlib/geo"
+	"github.com/xtxy/cxlib/logs"
+)
+
+// influence below this share of its source strength is dropped
+const influence_cutoff = 0.01
+
+type InfluenceSource struct {
+	Pos      geo.Vec2[int64]
+	Strength float64
+}
+
+type influenceLayer struct {
+	decay   float64
+	sources []InfluenceSource
+	values  []float64
+	dirty   bool
+}
+
+type penaltyLayer struct {
+	values []float64
+	weight float64
+}
+
+// InfluenceMap keeps named layers of values over the cells [0, width) x
+// [0, height) of a CellMap. A source of strength s gives s * decay^d to every
+// cell at walking distance d, walls stop it and the sources of a layer add
+// up. Changes only show after Update propagates them, reads never change the
+// map, so finders may share one InfluenceMap as long as no change or Update
+// runs during their searches.
+type InfluenceMap struct {
+	width  int64
+	height int64
+	finder *GraphFinder[geo.Vec2[int64]]
+	layers map[string]*influenceLayer
+}
+
+func NewInfluenceMap(cellMap CellMap, move int, width, height int64) *InfluenceMap {
+	influence := new(InfluenceMap)
+	influence.width = width
+	influence.height = height
+	influence.finder = NewGraphFinder[geo.Vec2[int64]](&gridGraph{cellMap: cellMap, move: move, width: width, height: height})
+	influence.layers = make(map[string]*influenceLayer)
+
+	return influence
+}
+
+// AddLayer creates or empties the layer name, decay is the share of the
+// influence kept per cell walked, in (0, 1).
+func (influence *InfluenceMap) AddLayer(name string, decay float64) {
+	if decay <= 0 || decay >= 1 {
+		logs.Error("influence.decay.out.of.range:", name, decay)
+		return
+	}
+
+	influence.layers[name] = &influenceLayer{
+		decay:  decay,
+		values: make([]float64, influence.width*influence.height),
+		dirty:  true,
+	}
+}
+
+func (influence *InfluenceMap) RemoveLayer(name string) {
+	delete(influence.layers, name)
+}
+
+func (influence *InfluenceMap) AddSource(name string, pos geo.Vec2[int64], strength float64) {
+	layer, ok := influence.layers[name]
+	if !ok {
+		logs.Error("influence.layer.not.found:", name)
+		return
+	}
+
+	layer.sources = append(layer.sources, InfluenceSource{Pos: pos, Strength: strength})
+	layer.dirty = true
+}
+
+// SetSources replaces the sources of a layer, e.g. every tower each frame.
+func (influence *InfluenceMap) SetSources(name string, sources []InfluenceSource) {
+	layer, ok := influence.layers[name]
+	if !ok {
+		logs.Error("influence.layer.not.found:", name)
+		return
+	}
+
+	layer.sources = append(layer.sources[:0], sources...)
+	layer.dirty = true
+}
+
+// Invalidate propagates every layer again on next Update, call it after the
+// walkability of the CellMap changed.
+func (influence *InfluenceMap) Invalidate() {
+	for _, v := range influence.layers {
+		v.dirty = true
+	}
+}
+
+func (influence *InfluenceMap) Value(name string, pos geo.Vec2[int64]) float64 {
+	values := influence.getValues(name)
+	if values == nil || !influence.contain(pos) {
+		return 0
+	}
+
+	return values[pos.Y*influence.width+pos.X]
+}
+
+// Update propagates the layers changed since the last Update.
+func (influence *InfluenceMap) Update() {
+	for _, v := range influence.layers {
+		if v.dirty {
+			influence.propagate(v)
+		}
+	}
+}
+
+func (influence *InfluenceMap) getValues(name string) []float64 {
+	layer, ok := influence.layers[name]
+	if !ok {
+		return nil
+	}
+
+	return layer.values
+}
+
+func (influence *InfluenceMap) propagate(layer *influenceLayer) {
+	clear(layer.values)
+
+	maxDistance := math.Log(influence_cutoff) / math.Log(layer.decay)
+
+	for _, source := range layer.sources {
+		if !influence.contain(source.Pos) {
+			continue
+		}
+
+		influence.finder.distances(source.Pos, func(pos geo.Vec2[int64], g float64) bool {
+			if g > maxDistance {
+				return false
+			}
+
+			// the conversion stops a fused multiply add, so every architecture
+			// sums the same values for COST_FIXED
+			layer.values[pos.Y*influence.width+pos.X] += float64(source.Strength * math.Pow(layer.decay, g))
+			return true
+		})
+	}
+
+	layer.dirty = false
+}
+
+func (influence *InfluenceMap) contain(pos geo.Vec2[int64]) bool {
+	return pos.X >= 0 && pos.Y >= 0 && pos.X < influence.width && pos.Y < influence.height
+}
@@ -0,0 +1,48 @@
+package jps
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+func TestInfluenceUpdate(t *testing.T) {
+	grid := NewGridMap(16, 16)
+	influence := NewInfluenceMap(grid, MOVE_DIAG_NEVER, 16, 16)
+	influence.AddLayer("danger", 0.5)
+	influence.AddSource("danger", geo.Vec2[int64]{X: 8, Y: 8}, 4)
+
+	pos := geo.Vec2[int64]{X: 8, Y: 10}
+	if v := influence.Value("danger", pos); v != 0 {
+		t.Fatalf("value %v before Update, want 0", v)
+	}
+
+	influence.Update()
+	if v := influence.Value("danger", pos); math.Abs(v-1) > 1e-9 {
+		t.Fatalf("value %v after Update, want 1", v)
+	}
+
+	// finders share the map, reads do not change it
+	var group sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		share := grid.Share()
+		finder := NewFinder(share, MOVE_DIAG_NEVER)
+		finder.SetInfluenceMap(influence)
+
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for j := 0; j < 20; j++ {
+				result, err := finder.FindResult(geo.Vec2[int64]{X: 8, Y: 0}, geo.Vec2[int64]{X: 8, Y: 15}, FindOptPenaltyLayer("danger", 10))
+				if err != nil || result.Cost <= 15 {
+					t.Errorf("cost %v %v, want a detour around the danger", result.Cost, err)
+					return
+				}
+			}
+		}()
+	}
+
+	group.Wait()
+}
@@ -0,0 +1,355 @@
+package jps
+
+import (
+	"encoding/binary"
+	"encoding/gob"
+	"io"
+	"math"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+const landmark_version = 1
+
+// landmark_max_side keeps width * height of a loaded table from overflowing
+const landmark_max_side = 1 << 30
+
+var landmarkMagic = [4]byte{'J', 'P', 'S', 'L'}
+
+// Landmarks keeps the walking distance from a few landmark cells to every
+// cell of [0, width) x [0, height) for the ALT heuristic. Distances ignore
+// cell costs and penalties so they stay a lower bound of any query cost, but
+// they follow the diagonal rule of move: a query with a stricter move mode
+// may use the tables, a more permissive one may not. Blocking cells only
+// makes them weaker, changing a blocked cell to walkable needs a rebuild and
+// queries whose capabilities open cells CanWalk refuses cannot use them.
+type Landmarks struct {
+	move      int
+	width     int64
+	height    int64
+	positions []geo.Vec2[int64]
+	tables    [][]float64
+}
+
+// gridGraph walks a CellMap with the diagonal rule of a move mode at the cost
+// of the search without penalties.
+type gridGraph struct {
+	cellMap CellMap
+	move    int
+	width   int64
+	height  int64
+}
+
+func (graph *gridGraph) Neighbors(pos geo.Vec2[int64], list []Edge[geo.Vec2[int64]]) []Edge[geo.Vec2[int64]] {
+	for dy := int64(-1); dy <= 1; dy++ {
+		for dx := int64(-1); dx <= 1; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+
+			nPos := geo.Vec2[int64]{X: pos.X + dx, Y: pos.Y + dy}
+			if nPos.X < 0 || nPos.Y < 0 || nPos.X >= graph.width || nPos.Y >= graph.height || !graph.cellMap.CanWalk(nPos) {
+				continue
+			}
+
+			if dx == 0 || dy == 0 {
+				list = append(list, Edge[geo.Vec2[int64]]{Node: nPos, Cost: 1})
+			} else if canMoveDiagonal(graph.cellMap.CanWalk, pos, dx, dy, graph.move) {
+				list = append(list, Edge[geo.Vec2[int64]]{Node: nPos, Cost: math.Sqrt2})
+			}
+		}
+	}
+
+	return list
+}
+
+func (graph *gridGraph) Heuristic(pos, end geo.Vec2[int64]) float64 {
+	return HeuristicOctile(pos, end)
+}
+
+// NewLandmarks picks count landmarks, each one the walkable cell farthest from
+// the landmarks picked before, and fills their distance tables. Every
+// disconnected area gets a landmark before any area gets a second one.
+func NewLandmarks(cellMap CellMap, move int, width, height int64, count int) *Landmarks {
+	landmarks := new(Landmarks)
+	landmarks.move = move
+	landmarks.width = width
+	landmarks.height = height
+
+	graph := &gridGraph{cellMap: cellMap, move: move, width: width, height: height}
+	finder := NewGraphFinder[geo.Vec2[int64]](graph)
+
+	seed, ok := landmarks.firstWalkable(cellMap)
+	if !ok {
+		return landmarks
+	}
+
+	// distance from the chosen landmarks, the first pick is the cell farthest
+	// from the first walkable cell and walkable cells it cannot reach come
+	// before it
+	nearest := landmarks.fillTable(finder, seed)
+	for i := range nearest {
+		if !cellMap.CanWalk(landmarks.position(i)) {
+			nearest[i] = 0
+		}
+	}
+
+	for len(landmarks.positions) < count {
+		best := -1
+		for i, v := range nearest {
+			if v > 0 && (best < 0 || v > nearest[best]) {
+				best = i
+			}
+		}
+
+		if best < 0 {
+			break
+		}
+
+		pos := landmarks.position(best)
+		table := landmarks.fillTable(finder, pos)
+		landmarks.positions = append(landmarks.positions, pos)
+		landmarks.tables = append(landmarks.tables, table)
+
+		for i, v := range table {
+			nearest[i] = min(nearest[i], v)
+		}
+	}
+
+	return landmarks
+}
+
+func (landmarks *Landmarks) firstWalkable(cellMap CellMap) (geo.Vec2[int64], bool) {
+	pos := geo.Vec2[int64]{}
+	for pos.Y = 0; pos.Y < landmarks.height; pos.Y++ {
+		for pos.X = 0; pos.X < landmarks.width; pos.X++ {
+			if cellMap.CanWalk(pos) {
+				return pos, true
+			}
+		}
+	}
+
+	return pos, false
+}
+
+func (landmarks *Landmarks) fillTable(finder *GraphFinder[geo.Vec2[int64]], pos geo.Vec2[int64]) []float64 {
+	table := make([]float64, landmarks.width*landmarks.height)
+	for i := range table {
+		table[i] = math.Inf(1)
+	}
+
+	finder.distances(pos, func(node geo.Vec2[int64], g float64) bool {
+		table[node.Y*landmarks.width+node.X] = g
+		return true
+	})
+
+	return table
+}
+
+func (landmarks *Landmarks) position(offset int) geo.Vec2[int64] {
+	return geo.Vec2[int64]{X: int64(offset) % landmarks.width, Y: int64(offset) / landmarks.width}
+}
+
+func (landmarks *Landmarks) Positions() []geo.Vec2[int64] {
+	return landmarks.positions
+}
+
+// Heuristic is the ALT estimate, the largest triangle inequality bound over
+// the landmarks and never less than the octile distance. Pass it to
+// SetHeuristic or FindOptHeuristic in COST_FLOAT mode.
+func (landmarks *Landmarks) Heuristic(pos, end geo.Vec2[int64]) float64 {
+	h := HeuristicOctile(pos, end)
+	if !landmarks.contain(pos) || !landmarks.contain(end) {
+		return h
+	}
+
+	posOffset := pos.Y*landmarks.width + pos.X
+	endOffset := end.Y*landmarks.width + end.X
+
+	for _, table := range landmarks.tables {
+		posDistance, endDistance := table[posOffset], table[endOffset]
+		if math.IsInf(posDistance, 1) || math.IsInf(endDistance, 1) {
+			continue
+		}
+
+		h = max(h, math.Abs(endDistance-posDistance))
+	}
+
+	return h
+}
+
+func (landmarks *Landmarks) contain(pos geo.Vec2[int64]) bool {
+	return pos.X >= 0 && pos.Y >= 0 && pos.X < landmarks.width && pos.Y < landmarks.height
+}
+
+type landmarkHeader struct {
+	Magic   [4]byte
+	Version uint32
+	Move    int32
+	Count   int32
+	Width   int64
+	Height  int64
+}
+
+// Save writes the landmarks in a little endian binary format that
+// LoadLandmarks reads back, so the tables can be built offline.
+func (landmarks *Landmarks) Save(w io.Writer) error {
+	header := landmarkHeader{
+		Magic:   landmarkMagic,
+		Version: landmark_version,
+		Move:    int32(landmarks.move),
+		Count:   int32(len(landmarks.positions)),
+		Width:   landmarks.width,
+		Height:  landmarks.height,
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, landmarks.positions); err != nil {
+		return err
+	}
+
+	for _, v := range landmarks.tables {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func LoadLandmarks(r io.Reader) (*Landmarks, error) {
+	header := landmarkHeader{}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+
+	if header.Magic != landmarkMagic || header.Version != landmark_version ||
+		!validMove(int(header.Move)) || header.Count < 0 ||
+		header.Width < 0 || header.Height < 0 || header.Width > landmark_max_side || header.Height > landmark_max_side {
+		return nil, ErrLandmarkFormat
+	}
+
+	landmarks := new(Landmarks)
+	landmarks.move = int(header.Move)
+	landmarks.width = header.Width
+	landmarks.height = header.Height
+
+	var err error
+	if landmarks.positions, err = readSlice[geo.Vec2[int64]](r, int64(header.Count)); err != nil {
+		return nil, err
+	}
+
+	for range landmarks.positions {
+		table, err := readSlice[float64](r, landmarks.width*landmarks.height)
+		if err != nil {
+			return nil, err
+		}
+
+		landmarks.tables = append(landmarks.tables, table)
+	}
+
+	return landmarks, nil
+}
+
+// GraphLandmarks is the ALT heuristic of a Graph. Distances run from the
+// landmarks only, which keeps the bound valid on directed graphs.
+type GraphLandmarks[N comparable] struct {
+	Nodes  []N
+	Tables []map[N]float64
+}
+
+// NewGraphLandmarks picks count landmarks among the nodes reachable from
+// seed, each one the node farthest from the landmarks picked before.
+func NewGraphLandmarks[N comparable](graph Graph[N], seed N, count int) *GraphLandmarks[N] {
+	landmarks := new(GraphLandmarks[N])
+	finder := NewGraphFinder(graph)
+
+	// nodes keeps the visit order so ties never depend on map order
+	nodes := make([]N, 0)
+	nearest := make(map[N]float64)
+	finder.distances(seed, func(node N, g float64) bool {
+		nodes = append(nodes, node)
+		nearest[node] = g
+		return true
+	})
+
+	for len(landmarks.Nodes) < count {
+		found := false
+		var best N
+		for _, v := range nodes {
+			if nearest[v] > 0 && (!found || nearest[v] > nearest[best]) {
+				found = true
+				best = v
+			}
+		}
+
+		if !found {
+			break
+		}
+
+		table := make(map[N]float64)
+		finder.distances(best, func(node N, g float64) bool {
+			table[node] = g
+			if v, ok := nearest[node]; ok && g < v {
+				nearest[node] = g
+			}
+
+			return true
+		})
+
+		landmarks.Nodes = append(landmarks.Nodes, best)
+		landmarks.Tables = append(landmarks.Tables, table)
+	}
+
+	return landmarks
+}
+
+// Heuristic is the largest bound d(landmark, goal) - d(landmark, node).
+func (landmarks *GraphLandmarks[N]) Heuristic(node, goal N) float64 {
+	h := 0.0
+	for _, table := range landmarks.Tables {
+		nodeDistance, ok1 := table[node]
+		goalDistance, ok2 := table[goal]
+		if ok1 && ok2 {
+			h = max(h, goalDistance-nodeDistance)
+		}
+	}
+
+	return h
+}
+
+// Save writes the landmarks with encoding/gob, N has to be gob encodable.
+func (landmarks *GraphLandmarks[N]) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(landmarks)
+}
+
+func LoadGraphLandmarks[N comparable](r io.Reader) (*GraphLandmarks[N], error) {
+	landmarks := new(GraphLandmarks[N])
+	if err := gob.NewDecoder(r).Decode(landmarks); err != nil {
+		return nil, err
+	}
+
+	if len(landmarks.Nodes) != len(landmarks.Tables) {
+		return nil, ErrLandmarkFormat
+	}
+
+	return landmarks, nil
+}
+
+type landmarkGraph[N comparable] struct {
+	Graph[N]
+	landmarks *GraphLandmarks[N]
+}
+
+func (graph *landmarkGraph[N]) Heuristic(node, goal N) float64 {
+	return max(graph.Graph.Heuristic(node, goal), graph.landmarks.Heuristic(node, goal))
+}
+
+// WithLandmarks wraps graph so its heuristic is the larger of its own and the
+// ALT estimate, give the result to NewGraphFinder.
+func WithLandmarks[N comparable](graph Graph[N], landmarks *GraphLandmarks[N]) Graph[N] {
+	return &landmarkGraph[N]{Graph: graph, landmarks: landmarks}
+}
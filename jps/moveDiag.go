@@ -13,43 +13,48 @@ func (jps *jpsMoveDiag) findNeighbors(pos geo.Vec2[int64]) []geo.Vec2[int64] {
 	if parentOk {
 		dx, dy := dir(pos, parentPos)
 		if dx != 0 && dy != 0 {
-			deltas := [20]int64{
+			deltas := [12]int64{
 				0, dy, 0, dy,
 				dx, 0, dx, 0,
 				dx, dy, dx, dy,
+			}
+			neighbors = jps.finder.findNeighbors(pos, deltas[:], nil, true)
+
+			forceDeltas := [8]int64{
 				-dx, 0, -dx, dy,
 				0, -dy, dx, -dy,
 			}
-
-			neighbors = jps.finder.findNeighbors(pos, deltas[:], nil, true)
+			if arr := jps.finder.findNeighbors(pos, forceDeltas[:], nil, false); len(arr) > 0 {
+				neighbors = append(neighbors, arr...)
+			}
 
 		} else if dx == 0 {
 			nPos := geo.Vec2[int64]{X: pos.X, Y: pos.Y + dy}
 			if jps.finder.canWalk(nPos) {
 				neighbors = append(neighbors, nPos)
+			}
 
-				deltas := [8]int64{
-					1, 0, 1, dy,
-					-1, 0, -1, dy,
-				}
-				newNeighbors := jps.finder.findNeighbors(pos, deltas[:], nil, false)
-				if len(newNeighbors) > 0 {
-					neighbors = append(neighbors, newNeighbors...)
-				}
+			deltas := [8]int64{
+				1, 0, 1, dy,
+				-1, 0, -1, dy,
+			}
+			newNeighbors := jps.finder.findNeighbors(pos, deltas[:], nil, false)
+			if len(newNeighbors) > 0 {
+				neighbors = append(neighbors, newNeighbors...)
 			}
 		} else {
 			nPos := geo.Vec2[int64]{X: pos.X + dx, Y: pos.Y}
 			if jps.finder.canWalk(nPos) {
 				neighbors = append(neighbors, nPos)
+			}
 
-				deltas := [8]int64{
-					0, 1, dx, 1,
-					0, -1, dx, -1,
-				}
-				newNeighbors := jps.finder.findNeighbors(pos, deltas[:], nil, false)
-				if len(newNeighbors) > 0 {
-					neighbors = append(neighbors, newNeighbors...)
-				}
+			deltas := [8]int64{
+				0, 1, dx, 1,
+				0, -1, dx, -1,
+			}
+			newNeighbors := jps.finder.findNeighbors(pos, deltas[:], nil, false)
+			if len(newNeighbors) > 0 {
+				neighbors = append(neighbors, newNeighbors...)
 			}
 		}
 	} else {
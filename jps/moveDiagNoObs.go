@@ -13,7 +13,7 @@ func (jps *jpsMoveDiagNoObs) findNeighbors(pos geo.Vec2[int64]) []geo.Vec2[int64
 	if parentOk {
 		dx, dy := dir(pos, parentPos)
 		if dx != 0 && dy != 0 {
-			deltas := [12]int64{
+			deltas := [8]int64{
 				0, dy, 0, dy,
 				dx, 0, dx, 0,
 			}
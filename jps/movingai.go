@@ -0,0 +1,249 @@
+package jps
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+// a map row has to fit one scanner line, the cell limit keeps a bad header
+// from allocating gigabytes
+const (
+	movingai_max_line  = 1024 * 1024
+	movingai_max_cells = 1 << 26
+)
+
+// Scenario is one query of a MovingAI .scen file, Optimal is the reference
+// octile length without corner cutting, i.e. the cost MOVE_DIAG_NO_OBS finds.
+type Scenario struct {
+	Bucket  int
+	Map     string
+	Width   int64
+	Height  int64
+	Start   geo.Vec2[int64]
+	Goal    geo.Vec2[int64]
+	Optimal float64
+}
+
+type ScenarioResult struct {
+	Index    int
+	Move     int
+	Found    bool
+	Cost     float64
+	Correct  bool
+	Duration time.Duration
+}
+
+type ScenarioSummary struct {
+	Move     int
+	Total    int
+	Correct  int
+	Duration time.Duration
+}
+
+func LoadMovingAIMapFile(path string) (*GridMap, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return LoadMovingAIMap(file)
+}
+
+// LoadMovingAIMap reads a .map file, '.', 'G' and 'S' are walkable and every
+// other terrain is blocked.
+func LoadMovingAIMap(r io.Reader) (*GridMap, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), movingai_max_line)
+
+	var width, height int64 = -1, -1
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "map" {
+			break
+		}
+
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("jps: bad map header line %q", scanner.Text())
+		}
+
+		var err error
+		switch fields[0] {
+		case "height":
+			height, err = strconv.ParseInt(fields[1], 10, 64)
+		case "width":
+			width, err = strconv.ParseInt(fields[1], 10, 64)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("jps: bad map header line %q: %w", scanner.Text(), err)
+		}
+	}
+
+	if width < 0 || height < 0 {
+		return nil, fmt.Errorf("jps: map header without width or height")
+	}
+
+	if width >= movingai_max_line || height > movingai_max_cells || width*height > movingai_max_cells {
+		return nil, fmt.Errorf("jps: map size %d x %d too large", width, height)
+	}
+
+	grid := NewGridMap(width, height)
+	pos := geo.Vec2[int64]{}
+
+	for pos.Y = 0; pos.Y < height; pos.Y++ {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+
+			return nil, fmt.Errorf("jps: map has %d rows, want %d", pos.Y, height)
+		}
+
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if int64(len(line)) < width {
+			return nil, fmt.Errorf("jps: map row %d has %d cells, want %d", pos.Y, len(line), width)
+		}
+
+		for pos.X = 0; pos.X < width; pos.X++ {
+			switch line[pos.X] {
+			case '.', 'G', 'S':
+			default:
+				grid.SetWalkable(pos, false)
+			}
+		}
+	}
+
+	return grid, nil
+}
+
+func LoadMovingAIScenFile(path string) ([]Scenario, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return LoadMovingAIScen(file)
+}
+
+func LoadMovingAIScen(r io.Reader) ([]Scenario, error) {
+	scanner := bufio.NewScanner(r)
+	scenarios := make([]Scenario, 0)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "version") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) != 9 {
+			fields = strings.Fields(text)
+		}
+
+		if len(fields) != 9 {
+			return nil, fmt.Errorf("jps: scen line %d has %d fields, want 9", line, len(fields))
+		}
+
+		var ints [7]int64
+		for i, v := range [7]int{0, 2, 3, 4, 5, 6, 7} {
+			value, err := strconv.ParseInt(fields[v], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("jps: scen line %d: %w", line, err)
+			}
+
+			ints[i] = value
+		}
+
+		optimal, err := strconv.ParseFloat(fields[8], 64)
+		if err != nil {
+			return nil, fmt.Errorf("jps: scen line %d: %w", line, err)
+		}
+
+		scenarios = append(scenarios, Scenario{
+			Bucket:  int(ints[0]),
+			Map:     fields[1],
+			Width:   ints[1],
+			Height:  ints[2],
+			Start:   geo.Vec2[int64]{X: ints[3], Y: ints[4]},
+			Goal:    geo.Vec2[int64]{X: ints[5], Y: ints[6]},
+			Optimal: optimal,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return scenarios, nil
+}
+
+// RunScenarios runs every scenario with every move mode using the octile
+// heuristic. A result is correct when its cost is within epsilon of the
+// reference, the references follow MOVE_DIAG_NO_OBS so other modes are only
+// reported for comparison.
+func RunScenarios(grid *GridMap, scenarios []Scenario, moves []int, epsilon float64) []ScenarioResult {
+	results := make([]ScenarioResult, 0, len(scenarios)*len(moves))
+	finder := NewFinder(grid, MOVE_DIAG_NO_OBS)
+	finder.SetHeuristic(HeuristicOctile)
+	list := make([]geo.Vec2[int64], 0)
+
+	for _, move := range moves {
+		finder.applyOptions([]FindOption{FindOptMove(move)})
+
+		for i, v := range scenarios {
+			result := ScenarioResult{Index: i, Move: move}
+
+			begin := time.Now()
+			var err error
+			list, err = finder.search(v.Start, v.Goal, list[:0])
+			result.Duration = time.Since(begin)
+
+			if err == nil {
+				result.Found = true
+				result.Cost = finder.pathCost
+				result.Correct = math.Abs(result.Cost-v.Optimal) <= epsilon
+			}
+
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+func SummarizeScenarios(results []ScenarioResult) []ScenarioSummary {
+	summaries := make([]ScenarioSummary, 0)
+	indexes := make(map[int]int)
+
+	for _, v := range results {
+		index, ok := indexes[v.Move]
+		if !ok {
+			index = len(summaries)
+			indexes[v.Move] = index
+			summaries = append(summaries, ScenarioSummary{Move: v.Move})
+		}
+
+		summary := &summaries[index]
+		summary.Total++
+		summary.Duration += v.Duration
+		if v.Correct {
+			summary.Correct++
+		}
+	}
+
+	return summaries
+}
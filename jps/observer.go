@@ -0,0 +1,16 @@
+package jps
+
+import "github.com/xtxy/cxlib/geo"
+
+// Observer is told about every step of a search, see FindOptObserver. A
+// finder without observers does not pay for the calls.
+type Observer interface {
+	// OnOpen is called when pos joins the open list with parent.
+	OnOpen(pos, parent geo.Vec2[int64], g, h float64)
+	// OnClose is called when pos is taken from the open list to be expanded.
+	OnClose(pos geo.Vec2[int64], g float64)
+	// OnJump is called for every jump point found while expanding from.
+	OnJump(from, to geo.Vec2[int64])
+	// OnImprove is called when an open cell gets a cheaper parent.
+	OnImprove(pos, parent geo.Vec2[int64], oldG, newG float64)
+}
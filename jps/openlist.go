@@ -0,0 +1,154 @@
+package jps
+
+type openItem[N comparable] struct {
+	node   N
+	f      float64
+	h      float64
+	serial uint64
+}
+
+// openList is the binary heap shared by Finder and GraphFinder. Improving a
+// node pushes it again, the caller skips the stale copies when popping. Ties
+// on f go to the lowest h, then to less when set, then to the first pushed.
+type openList[N comparable] struct {
+	items  []openItem[N]
+	less   func(a, b N) bool
+	serial uint64
+}
+
+func (list *openList[N]) Len() int {
+	return len(list.items)
+}
+
+func (list *openList[N]) Reset() {
+	clear(list.items)
+	list.items = list.items[:0]
+	list.serial = 0
+}
+
+func (list *openList[N]) Push(node N, g, h float64) {
+	list.serial++
+	list.items = append(list.items, openItem[N]{node: node, f: g + h, h: h, serial: list.serial})
+
+	for i := len(list.items) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if !list.lessItem(i, parent) {
+			break
+		}
+
+		list.items[i], list.items[parent] = list.items[parent], list.items[i]
+		i = parent
+	}
+}
+
+func (list *openList[N]) Pop() (N, float64) {
+	top := list.items[0]
+	last := len(list.items) - 1
+	list.items[0] = list.items[last]
+	list.items[last] = openItem[N]{}
+	list.items = list.items[:last]
+
+	for i := 0; ; {
+		child := i*2 + 1
+		if child >= last {
+			break
+		}
+
+		if child+1 < last && list.lessItem(child+1, child) {
+			child++
+		}
+
+		if !list.lessItem(child, i) {
+			break
+		}
+
+		list.items[i], list.items[child] = list.items[child], list.items[i]
+		i = child
+	}
+
+	return top.node, top.f
+}
+
+func (list *openList[N]) lessItem(i, j int) bool {
+	a, b := &list.items[i], &list.items[j]
+	if a.f != b.f {
+		return a.f < b.f
+	}
+
+	if a.h != b.h {
+		return a.h < b.h
+	}
+
+	if list.less != nil && a.node != b.node {
+		return list.less(a.node, b.node)
+	}
+
+	return a.serial < b.serial
+}
+
+// searchSpace keeps the per-node data of one search for searchLoop.
+// expandNode opens or improves the successors of a closed node.
+type searchSpace[N comparable] interface {
+	isClosed(node N) bool
+	closeNode(node N) (g float64)
+	expandNode(node N, g float64)
+}
+
+// searchLoop is the best first search of Finder and GraphFinder. It pops the
+// open nodes in f order, closes each one once and expands it unless visit
+// returns false, which ends the search with a nil error. ErrBudget and
+// ErrUnreachable tell that the budget or the open nodes ran out.
+func searchLoop[N comparable](space searchSpace[N], opens *openList[N], budget *searchBudget, start N, h float64, visit func(node N, g float64) bool) error {
+	opens.Reset()
+	opens.Push(start, 0, h)
+
+	for opens.Len() > 0 {
+		node, _ := opens.Pop()
+		if space.isClosed(node) {
+			continue
+		}
+
+		if !budget.spend() {
+			return ErrBudget
+		}
+
+		g := space.closeNode(node)
+		if !visit(node, g) {
+			return nil
+		}
+
+		space.expandNode(node, g)
+	}
+
+	return ErrUnreachable
+}
+
+// searchBudget stops a search after limit expanded nodes, 0 means no limit.
+type searchBudget struct {
+	limit    int
+	expanded int
+}
+
+func (budget *searchBudget) reset(limit int) {
+	budget.limit = limit
+	budget.expanded = 0
+}
+
+// spend counts one expanded node and tells whether the budget allowed it.
+func (budget *searchBudget) spend() bool {
+	if budget.limit > 0 && budget.expanded >= budget.limit {
+		return false
+	}
+
+	budget.expanded++
+	return true
+}
+
+// Result is what a search returns besides its error. Path runs from end back
+// to start (excluded) unless the query asked for the reverse order, Cost is
+// its cost and Expanded the number of nodes taken from the open list.
+type Result[N comparable] struct {
+	Path     []N
+	Cost     float64
+	Expanded int
+}
@@ -0,0 +1,94 @@
+package jps
+
+import "github.com/xtxy/cxlib/geo"
+
+// ValidatePath checks a path from start (excluded) to end in the order Find
+// returns by default, end first. Every step has to be a straight or diagonal
+// line over walkable cells whose diagonal moves follow the move mode, Index of
+// a PathError counts in path as given.
+func ValidatePath(cellMap CellMap, start geo.Vec2[int64], path []geo.Vec2[int64], move int) error {
+	prev := start
+
+	for i := len(path) - 1; i >= 0; i-- {
+		v := path[i]
+		delta := v.Sub(prev)
+		if delta.X != 0 && delta.Y != 0 && abs(delta.X) != abs(delta.Y) {
+			return &PathError{Index: i, Pos: v, Err: ErrPathNotLine}
+		}
+
+		dx, dy := dir(v, prev)
+		for pos := prev; pos != v; {
+			next := geo.Vec2[int64]{X: pos.X + dx, Y: pos.Y + dy}
+			if !cellMap.CanWalk(next) {
+				return &PathError{Index: i, Pos: next, Err: ErrPathBlocked}
+			}
+
+			if dx != 0 && dy != 0 && !canMoveDiagonal(cellMap.CanWalk, pos, dx, dy, move) {
+				return &PathError{Index: i, Pos: next, Err: ErrPathCorner}
+			}
+
+			pos = next
+		}
+
+		prev = v
+	}
+
+	return nil
+}
+
+func canMoveDiagonal(canWalk func(geo.Vec2[int64]) bool, pos geo.Vec2[int64], dx, dy int64, move int) bool {
+	switch move {
+	case MOVE_DIAG_NEVER:
+		return false
+
+	case MOVE_DIAG_NO_OBS:
+		return canWalk(geo.Vec2[int64]{X: pos.X + dx, Y: pos.Y}) &&
+			canWalk(geo.Vec2[int64]{X: pos.X, Y: pos.Y + dy})
+
+	case MOVE_DIAG_MOST_ONE:
+		return canWalk(geo.Vec2[int64]{X: pos.X + dx, Y: pos.Y}) ||
+			canWalk(geo.Vec2[int64]{X: pos.X, Y: pos.Y + dy})
+	}
+
+	return true
+}
+
+// PathLength is the geometric length of a path in Find order, straight steps
+// count 1 and diagonal steps sqrt(2) like the search does.
+func PathLength(start geo.Vec2[int64], path []geo.Vec2[int64]) float64 {
+	length := 0.0
+	prev := start
+
+	for i := len(path) - 1; i >= 0; i-- {
+		length += getG(path[i], prev)
+		prev = path[i]
+	}
+
+	return length
+}
+
+// PathCost is PathLength plus the cost of every cell entered when cellMap is
+// a CostCellMap, the cost a search over cellMap gives the path.
+func PathCost(cellMap CellMap, start geo.Vec2[int64], path []geo.Vec2[int64]) float64 {
+	cost := PathLength(start, path)
+
+	costMap, ok := cellMap.(CostCellMap)
+	if !ok {
+		return cost
+	}
+
+	prev := start
+	for i := len(path) - 1; i >= 0; i-- {
+		v := path[i]
+		dx, dy := dir(v, prev)
+		for pos := prev; pos != v; {
+			pos.X += dx
+			pos.Y += dy
+			cost += costMap.GetCost(pos)
+		}
+
+		prev = v
+	}
+
+	return cost
+}
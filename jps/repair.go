@@ -0,0 +1,180 @@
+package jps
+
+import (
+	"slices"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+// RepairPath fixes a path from start (excluded) to end after some of its
+// cells became blocked. Every broken stretch is replaced by a detour from the
+// last walkable cell before it to the first one after it, searched within
+// window cells around both. When a detour fails the whole path is searched
+// again. path and the result are in the order Find returns, end first unless
+// FindOptReversePath is set, an intact path is returned as is.
+func (finder *Finder) RepairPath(start geo.Vec2[int64], path []geo.Vec2[int64], window int64, options ...FindOption) ([]geo.Vec2[int64], error) {
+	finder.applyOptions(options)
+	finder.startPos = start
+
+	if len(path) == 0 {
+		return path, nil
+	}
+
+	forward := path
+	if !finder.reversePath {
+		forward = slices.Clone(path)
+		slices.Reverse(forward)
+	}
+
+	repaired, broken, err := finder.repairForward(start, forward, window)
+	if err != nil || !broken {
+		return path, err
+	}
+
+	if !finder.reversePath {
+		slices.Reverse(repaired)
+	}
+
+	return repaired, nil
+}
+
+// repairForward is RepairPath on a path ordered from start, broken tells
+// whether the path had to change.
+func (finder *Finder) repairForward(start geo.Vec2[int64], path []geo.Vec2[int64], window int64) ([]geo.Vec2[int64], bool, error) {
+	cells := appendCells([]geo.Vec2[int64]{start}, start, path)
+	repaired := make([]geo.Vec2[int64], 0, len(cells))
+	broken := false
+
+	for i := 0; i < len(cells)-1; {
+		repaired = append(repaired, cells[i])
+		if finder.canStep(cells[i], cells[i+1]) {
+			i++
+			continue
+		}
+
+		broken = true
+
+		next := i + 1
+		for next < len(cells) && !finder.canWalk(cells[next]) {
+			next++
+		}
+
+		if next == len(cells) {
+			list, err := finder.searchForward(start, path[len(path)-1])
+			return list, true, err
+		}
+
+		detour, ok := finder.searchDetour(cells[i], cells[next], window)
+		if !ok {
+			list, err := finder.searchForward(start, path[len(path)-1])
+			return list, true, err
+		}
+
+		// the detour ends on cells[next], which the loop appends again
+		repaired = appendCells(repaired, cells[i], detour)
+		repaired = repaired[:len(repaired)-1]
+		i = next
+	}
+
+	if !broken {
+		return path, false, nil
+	}
+
+	repaired = append(repaired, cells[len(cells)-1])
+
+	return compressCells(repaired), true, nil
+}
+
+// canStep tells whether the query may move between the neighbor cells.
+func (finder *Finder) canStep(from, to geo.Vec2[int64]) bool {
+	if !finder.canWalk(to) {
+		return false
+	}
+
+	dx, dy := dir(to, from)
+
+	return dx == 0 || dy == 0 || canMoveDiagonal(finder.canWalk, from, dx, dy, finder.moveType)
+}
+
+// searchDetour searches from one cell to the other inside the bounding box of
+// both grown by window, the path returned runs forward.
+func (finder *Finder) searchDetour(from, to geo.Vec2[int64], window int64) ([]geo.Vec2[int64], bool) {
+	rect := geo.Rect[int64]{
+		X:      min(from.X, to.X) - window,
+		Y:      min(from.Y, to.Y) - window,
+		Width:  abs(from.X-to.X) + window*2,
+		Height: abs(from.Y-to.Y) + window*2,
+	}
+
+	within, nearest := finder.within, finder.nearest
+	if within != nil {
+		x1, y1 := max(rect.X, within.X), max(rect.Y, within.Y)
+		x2 := min(rect.X+rect.Width, within.X+within.Width)
+		y2 := min(rect.Y+rect.Height, within.Y+within.Height)
+		if x2 < x1 || y2 < y1 {
+			return nil, false
+		}
+
+		rect = geo.Rect[int64]{X: x1, Y: y1, Width: x2 - x1, Height: y2 - y1}
+	}
+
+	finder.within = &rect
+	finder.nearest = false
+	list, err := finder.search(from, to, make([]geo.Vec2[int64], 0))
+	finder.within, finder.nearest = within, nearest
+
+	if err != nil {
+		return nil, false
+	}
+
+	slices.Reverse(list)
+	return list, true
+}
+
+func (finder *Finder) searchForward(start, end geo.Vec2[int64]) ([]geo.Vec2[int64], error) {
+	list, err := finder.search(start, end, make([]geo.Vec2[int64], 0))
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(list)
+	return list, nil
+}
+
+// appendCells appends every cell of the straight or diagonal steps from
+// start through the waypoints, start excluded.
+func appendCells(list []geo.Vec2[int64], start geo.Vec2[int64], waypoints []geo.Vec2[int64]) []geo.Vec2[int64] {
+	prev := start
+	for _, v := range waypoints {
+		for cell := prev; cell != v; {
+			dx, dy := dir(v, cell)
+			cell.X += dx
+			cell.Y += dy
+			list = append(list, cell)
+		}
+
+		prev = v
+	}
+
+	return list
+}
+
+// compressCells turns neighbor cells starting with the start cell into
+// waypoints where the direction changes, start excluded.
+func compressCells(cells []geo.Vec2[int64]) []geo.Vec2[int64] {
+	path := make([]geo.Vec2[int64], 0)
+	for i := 1; i < len(cells); i++ {
+		if i == len(cells)-1 {
+			path = append(path, cells[i])
+			break
+		}
+
+		dx1, dy1 := dir(cells[i], cells[i-1])
+		dx2, dy2 := dir(cells[i+1], cells[i])
+		if dx1 != dx2 || dy1 != dy2 {
+			path = append(path, cells[i])
+		}
+	}
+
+	return path
+}
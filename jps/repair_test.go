@@ -0,0 +1,68 @@
+package jps
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+func TestRepairPathOrder(t *testing.T) {
+	rnd := rand.New(rand.NewSource(9))
+	repaired := 0
+
+	for round := 0; round < 40; round++ {
+		grid := randomGrid(rnd, 24, 24, 0.15)
+		finder := NewFinder(grid, MOVE_DIAG_NO_OBS)
+		start, end := randomWalkable(rnd, grid), randomWalkable(rnd, grid)
+
+		path, err := finder.FindPath(start, end)
+		if err != nil || len(path) == 0 {
+			continue
+		}
+
+		cells := appendCells(make([]geo.Vec2[int64], 0), start, forward(path))
+		if len(cells) < 3 {
+			continue
+		}
+
+		grid.SetWalkable(cells[len(cells)/2], false)
+
+		for _, reverse := range []bool{false, true} {
+			input := path
+			if reverse {
+				input = forward(path)
+			}
+
+			result, err := finder.RepairPath(start, input, 4, FindOptReversePath(reverse))
+			if err != nil {
+				continue
+			}
+
+			if reverse {
+				result = forward(result)
+			}
+
+			if result[0] != end {
+				t.Fatalf("reverse %v: repaired %v does not lead to %v", reverse, result, end)
+			}
+
+			if err := ValidatePath(grid, start, result, MOVE_DIAG_NO_OBS); err != nil {
+				t.Fatalf("reverse %v: repaired %v: %v", reverse, result, err)
+			}
+
+			repaired++
+		}
+
+		// an intact path comes back unchanged
+		grid.SetWalkable(cells[len(cells)/2], true)
+		if result, _ := finder.RepairPath(start, path, 4); !slices.Equal(result, path) {
+			t.Fatalf("intact %v came back as %v", path, result)
+		}
+	}
+
+	if repaired == 0 {
+		t.Fatal("no path was repaired")
+	}
+}
@@ -0,0 +1,306 @@
+package jps
+
+import (
+	"container/heap"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+// PathRequest is one query for a Service. Higher Priority runs first, a zero
+// Deadline never expires. The Deadline is only checked before the search
+// starts, give a FindOptBudget to bound a search once it runs. Requests with
+// equal Start, End and Key share one search while it is queued or running;
+// requests with Options are only shared when Key is set, so give different
+// Keys to different options.
+type PathRequest struct {
+	Start    geo.Vec2[int64]
+	End      geo.Vec2[int64]
+	Options  []FindOption
+	Key      string
+	Priority int
+	Deadline time.Time
+}
+
+// PathResponse is ordered like Find returns, Latency runs from Submit to the
+// end of the search.
+type PathResponse struct {
+	Path    []geo.Vec2[int64]
+	Cost    float64
+	Err     error
+	Latency time.Duration
+}
+
+type ServiceStats struct {
+	Queued     int
+	Running    int
+	Done       uint64
+	Deduped    uint64
+	Expired    uint64
+	AvgLatency time.Duration
+	MaxLatency time.Duration
+}
+
+type serviceKey struct {
+	start geo.Vec2[int64]
+	end   geo.Vec2[int64]
+	key   string
+}
+
+type serviceWaiter struct {
+	submitted time.Time
+	callback  func(PathResponse)
+}
+
+type serviceJob struct {
+	request PathRequest
+	key     serviceKey
+	shared  bool
+	waiters []serviceWaiter
+	serial  uint64
+	index   int
+}
+
+type serviceQueue []*serviceJob
+
+func (q serviceQueue) Len() int { return len(q) }
+func (q serviceQueue) Less(i, j int) bool {
+	if q[i].request.Priority != q[j].request.Priority {
+		return q[i].request.Priority > q[j].request.Priority
+	}
+
+	return q[i].serial < q[j].serial
+}
+func (q serviceQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+func (q *serviceQueue) Push(x any) {
+	job := x.(*serviceJob)
+	job.index = len(*q)
+	*q = append(*q, job)
+}
+func (q *serviceQueue) Pop() any {
+	old := *q
+	job := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	job.index = -1
+	return job
+}
+
+// Service answers path requests from any goroutine with a pool of finders,
+// one per worker.
+type Service struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	queue   serviceQueue
+	jobs    map[serviceKey]*serviceJob
+	serial  uint64
+	closed  bool
+	running int
+	group   sync.WaitGroup
+
+	done         uint64
+	deduped      uint64
+	expired      uint64
+	totalLatency time.Duration
+	maxLatency   time.Duration
+}
+
+// NewService starts workers goroutines, each searching with the finder
+// newFinder returns. Finders must not share scratch data: give each one its
+// own CellMap, e.g. GridMap.Share, and its own ComponentIndex. They may share
+// one InfluenceMap that is not changed while the service runs searches.
+func NewService(workers int, newFinder func() *Finder) *Service {
+	service := new(Service)
+	service.cond = sync.NewCond(&service.mutex)
+	service.jobs = make(map[serviceKey]*serviceJob)
+
+	for i := 0; i < max(workers, 1); i++ {
+		finder := newFinder()
+		service.group.Add(1)
+		go service.work(finder)
+	}
+
+	return service
+}
+
+// Submit queues request and returns the channel its response is sent on.
+func (service *Service) Submit(request PathRequest) <-chan PathResponse {
+	ch := make(chan PathResponse, 1)
+	service.SubmitFunc(request, func(response PathResponse) {
+		ch <- response
+	})
+
+	return ch
+}
+
+// SubmitFunc queues request and calls callback from a worker goroutine with
+// its response. The callback must not call Close, which waits for the
+// workers and would wait for the callback itself.
+func (service *Service) SubmitFunc(request PathRequest, callback func(PathResponse)) {
+	waiter := serviceWaiter{submitted: time.Now(), callback: callback}
+
+	service.mutex.Lock()
+
+	if service.closed {
+		service.mutex.Unlock()
+		callback(PathResponse{Err: ErrServiceClosed})
+		return
+	}
+
+	key := serviceKey{start: request.Start, end: request.End, key: request.Key}
+	shared := len(request.Options) == 0 || request.Key != ""
+
+	if job, ok := service.jobs[key]; ok && shared {
+		job.waiters = append(job.waiters, waiter)
+		service.deduped++
+
+		// the shared search runs as urgent and as long as its most demanding
+		// waiter allows
+		if job.index >= 0 && request.Priority > job.request.Priority {
+			job.request.Priority = request.Priority
+			heap.Fix(&service.queue, job.index)
+		}
+
+		if job.request.Deadline.IsZero() || request.Deadline.IsZero() {
+			job.request.Deadline = time.Time{}
+		} else if request.Deadline.After(job.request.Deadline) {
+			job.request.Deadline = request.Deadline
+		}
+
+		service.mutex.Unlock()
+		return
+	}
+
+	service.serial++
+	job := &serviceJob{
+		request: request,
+		key:     key,
+		shared:  shared,
+		waiters: []serviceWaiter{waiter},
+		serial:  service.serial,
+	}
+
+	if shared {
+		service.jobs[key] = job
+	}
+
+	heap.Push(&service.queue, job)
+	service.mutex.Unlock()
+	service.cond.Signal()
+}
+
+func (service *Service) work(finder *Finder) {
+	defer service.group.Done()
+
+	for {
+		service.mutex.Lock()
+		for len(service.queue) == 0 && !service.closed {
+			service.cond.Wait()
+		}
+
+		if service.closed {
+			service.mutex.Unlock()
+			return
+		}
+
+		job := heap.Pop(&service.queue).(*serviceJob)
+		deadline := job.request.Deadline
+		service.running++
+		service.mutex.Unlock()
+
+		response := PathResponse{}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			response.Err = ErrDeadline
+		} else {
+			result, err := finder.FindResult(job.request.Start, job.request.End, job.request.Options...)
+			response.Path, response.Cost, response.Err = result.Path, result.Cost, err
+		}
+
+		service.finish(job, response)
+	}
+}
+
+func (service *Service) finish(job *serviceJob, response PathResponse) {
+	now := time.Now()
+
+	service.mutex.Lock()
+	if job.shared {
+		delete(service.jobs, job.key)
+	}
+
+	service.running--
+	if response.Err == ErrDeadline {
+		service.expired++
+	}
+
+	waiters := job.waiters
+	for _, v := range waiters {
+		latency := now.Sub(v.submitted)
+		service.done++
+		service.totalLatency += latency
+		service.maxLatency = max(service.maxLatency, latency)
+	}
+	service.mutex.Unlock()
+
+	for i, v := range waiters {
+		waiterResponse := response
+		waiterResponse.Latency = now.Sub(v.submitted)
+		if i > 0 {
+			waiterResponse.Path = slices.Clone(response.Path)
+		}
+
+		v.callback(waiterResponse)
+	}
+}
+
+func (service *Service) Stats() ServiceStats {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	stats := ServiceStats{
+		Queued:     len(service.queue),
+		Running:    service.running,
+		Done:       service.done,
+		Deduped:    service.deduped,
+		Expired:    service.expired,
+		MaxLatency: service.maxLatency,
+	}
+
+	if service.done > 0 {
+		stats.AvgLatency = service.totalLatency / time.Duration(service.done)
+	}
+
+	return stats
+}
+
+// Close stops the workers once their running searches end, queued requests
+// get ErrServiceClosed. It blocks until the workers return, so never call it
+// from a SubmitFunc callback.
+func (service *Service) Close() {
+	service.mutex.Lock()
+	if service.closed {
+		service.mutex.Unlock()
+		return
+	}
+
+	service.closed = true
+	queue := service.queue
+	service.queue = nil
+	clear(service.jobs)
+	service.mutex.Unlock()
+
+	service.cond.Broadcast()
+	service.group.Wait()
+
+	for _, job := range queue {
+		for _, v := range job.waiters {
+			v.callback(PathResponse{Err: ErrServiceClosed, Latency: time.Since(v.submitted)})
+		}
+	}
+}
@@ -0,0 +1,701 @@
+package jps
+
+import (
+	"encoding/binary"
+	"io"
+	"slices"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+const subgoal_version = 1
+
+// subgoal_max_side keeps width * height of a loaded graph from overflowing
+const subgoal_max_side = 1 << 30
+
+var subgoalMagic = [4]byte{'J', 'P', 'S', 'G'}
+
+// subgoal_epsilon absorbs the float error of summed octile distances
+const subgoal_epsilon = 1e-9
+
+// SubgoalGraph answers the same queries as MOVE_DIAG_NO_OBS on a static map
+// by searching between the convex corners of the obstacles only. The two
+// level graph moves the subgoals that no shortest path needs to a local level
+// so queries search fewer of them. A SubgoalGraph keeps scratch data for its
+// queries and is not safe for concurrent use.
+type SubgoalGraph struct {
+	cellMap  CellMap
+	width    int64
+	height   int64
+	twoLevel bool
+
+	positions []geo.Vec2[int64]
+	ids       map[geo.Vec2[int64]]int32
+	global    []bool
+	edges     [][]int32
+	globals   [][]int32
+	vias      map[[2]int32]int32
+
+	query  *subgoalQuery
+	finder *GraphFinder[int32]
+}
+
+// NewSubgoalGraph builds the graph of the cells [0, width) x [0, height),
+// twoLevel builds the two level graph. The map must not change afterwards.
+func NewSubgoalGraph(cellMap CellMap, width, height int64, twoLevel bool) *SubgoalGraph {
+	graph := newSubgoalGraph(cellMap, width, height, twoLevel)
+
+	pos := geo.Vec2[int64]{}
+	for pos.Y = 0; pos.Y < height; pos.Y++ {
+		for pos.X = 0; pos.X < width; pos.X++ {
+			if graph.isCorner(pos) {
+				graph.ids[pos] = int32(len(graph.positions))
+				graph.positions = append(graph.positions, pos)
+			}
+		}
+	}
+
+	sets := make([]map[int32]struct{}, len(graph.positions))
+	for i := range sets {
+		sets[i] = make(map[int32]struct{})
+	}
+
+	for i, v := range graph.positions {
+		graph.scan(v, graph.isSubgoal, func(pos geo.Vec2[int64]) {
+			id := graph.ids[pos]
+			sets[i][id] = struct{}{}
+			sets[id][int32(i)] = struct{}{}
+		})
+	}
+
+	graph.edges = make([][]int32, len(graph.positions))
+	graph.global = make([]bool, len(graph.positions))
+	for i, set := range sets {
+		for k := range set {
+			graph.edges[i] = append(graph.edges[i], k)
+		}
+
+		slices.Sort(graph.edges[i])
+		graph.global[i] = true
+	}
+
+	graph.globals = make([][]int32, len(graph.positions))
+	for i, v := range graph.edges {
+		graph.globals[i] = slices.Clone(v)
+	}
+
+	if twoLevel {
+		graph.buildLevels()
+	}
+
+	return graph
+}
+
+func newSubgoalGraph(cellMap CellMap, width, height int64, twoLevel bool) *SubgoalGraph {
+	graph := new(SubgoalGraph)
+	graph.cellMap = cellMap
+	graph.width = width
+	graph.height = height
+	graph.twoLevel = twoLevel
+	graph.ids = make(map[geo.Vec2[int64]]int32)
+	graph.vias = make(map[[2]int32]int32)
+	graph.query = &subgoalQuery{graph: graph}
+	graph.finder = NewGraphFinder[int32](graph.query)
+
+	return graph
+}
+
+func (graph *SubgoalGraph) Subgoals() []geo.Vec2[int64] {
+	return graph.positions
+}
+
+func (graph *SubgoalGraph) canWalk(pos geo.Vec2[int64]) bool {
+	return pos.X >= 0 && pos.Y >= 0 && pos.X < graph.width && pos.Y < graph.height && graph.cellMap.CanWalk(pos)
+}
+
+// isCorner tells whether pos touches the convex corner of an obstacle.
+func (graph *SubgoalGraph) isCorner(pos geo.Vec2[int64]) bool {
+	if !graph.canWalk(pos) {
+		return false
+	}
+
+	for dy := int64(-1); dy <= 1; dy += 2 {
+		for dx := int64(-1); dx <= 1; dx += 2 {
+			if !graph.canWalk(geo.Vec2[int64]{X: pos.X + dx, Y: pos.Y + dy}) &&
+				graph.canWalk(geo.Vec2[int64]{X: pos.X + dx, Y: pos.Y}) &&
+				graph.canWalk(geo.Vec2[int64]{X: pos.X, Y: pos.Y + dy}) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (graph *SubgoalGraph) isSubgoal(pos geo.Vec2[int64]) bool {
+	_, ok := graph.ids[pos]
+	return ok
+}
+
+func (graph *SubgoalGraph) canMoveDiagonal(pos geo.Vec2[int64], dx, dy int64) bool {
+	return graph.canWalk(geo.Vec2[int64]{X: pos.X + dx, Y: pos.Y + dy}) &&
+		canMoveDiagonal(graph.canWalk, pos, dx, dy, MOVE_DIAG_NO_OBS)
+}
+
+// clearance counts the steps from pos towards dx, dy before a blocked cell
+// or up to the first target, at most limit.
+func (graph *SubgoalGraph) clearance(pos geo.Vec2[int64], dx, dy, limit int64, isTarget func(geo.Vec2[int64]) bool) int64 {
+	var steps int64
+	for steps < limit {
+		next := geo.Vec2[int64]{X: pos.X + dx*(steps+1), Y: pos.Y + dy*(steps+1)}
+		if !graph.canWalk(next) {
+			break
+		}
+
+		steps++
+		if isTarget(next) {
+			break
+		}
+	}
+
+	return steps
+}
+
+// scan visits the targets directly h-reachable from pos: reachable by a
+// shortest diagonal first path that passes no other target.
+func (graph *SubgoalGraph) scan(pos geo.Vec2[int64], isTarget func(geo.Vec2[int64]) bool, visit func(geo.Vec2[int64])) {
+	limit := graph.width + graph.height
+
+	cardinal := func(from geo.Vec2[int64], dx, dy, limit int64) int64 {
+		steps := graph.clearance(from, dx, dy, limit, isTarget)
+		if target := (geo.Vec2[int64]{X: from.X + dx*steps, Y: from.Y + dy*steps}); steps > 0 && isTarget(target) {
+			visit(target)
+			steps--
+		}
+
+		return steps
+	}
+
+	for _, v := range [4][2]int64{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+		cardinal(pos, v[0], v[1], limit)
+	}
+
+	for dy := int64(-1); dy <= 1; dy += 2 {
+		for dx := int64(-1); dx <= 1; dx += 2 {
+			maxX := graph.clearance(pos, dx, 0, limit, isTarget)
+			maxY := graph.clearance(pos, 0, dy, limit, isTarget)
+
+			for cell := pos; graph.canMoveDiagonal(cell, dx, dy); {
+				cell.X += dx
+				cell.Y += dy
+				if isTarget(cell) {
+					visit(cell)
+					break
+				}
+
+				maxX = min(maxX, cardinal(cell, dx, 0, maxX))
+				maxY = min(maxY, cardinal(cell, 0, dy, maxY))
+			}
+		}
+	}
+}
+
+// buildLevels moves every subgoal whose neighbors can bypass it to the local
+// level, adding the bypass edges to the global level.
+func (graph *SubgoalGraph) buildLevels() {
+	view := &subgoalView{graph: graph}
+	finder := NewGraphFinder[int32](view)
+
+	for s := range graph.positions {
+		id := int32(s)
+		neighbors := graph.globals[id]
+		bypasses := make([][2]int32, 0)
+		necessary := false
+
+		view.skip = id
+		for i := 0; i < len(neighbors) && !necessary; i++ {
+			for j := i + 1; j < len(neighbors); j++ {
+				p, q := neighbors[i], neighbors[j]
+				length := graph.cost(p, id) + graph.cost(id, q)
+
+				found, _ := finder.run(p, q, true, func(node int32, g float64) bool {
+					return g <= length+subgoal_epsilon
+				})
+				clear(finder.nodes)
+
+				if found {
+					continue
+				}
+
+				if length > graph.cost(p, q)+subgoal_epsilon {
+					necessary = true
+					break
+				}
+
+				bypasses = append(bypasses, [2]int32{p, q})
+			}
+		}
+
+		if necessary {
+			continue
+		}
+
+		graph.global[id] = false
+		for _, v := range neighbors {
+			graph.globals[v] = deleteSorted(graph.globals[v], id)
+		}
+		graph.globals[id] = nil
+
+		for _, v := range bypasses {
+			graph.globals[v[0]] = insertSorted(graph.globals[v[0]], v[1])
+			graph.globals[v[1]] = insertSorted(graph.globals[v[1]], v[0])
+			graph.vias[edgeKey(v[0], v[1])] = id
+		}
+	}
+}
+
+func (graph *SubgoalGraph) cost(a, b int32) float64 {
+	return HeuristicOctile(graph.positions[a], graph.positions[b])
+}
+
+func edgeKey(a, b int32) [2]int32 {
+	return [2]int32{min(a, b), max(a, b)}
+}
+
+func insertSorted(list []int32, v int32) []int32 {
+	if i, ok := slices.BinarySearch(list, v); !ok {
+		list = slices.Insert(list, i, v)
+	}
+
+	return list
+}
+
+func deleteSorted(list []int32, v int32) []int32 {
+	if i, ok := slices.BinarySearch(list, v); ok {
+		list = slices.Delete(list, i, i+1)
+	}
+
+	return list
+}
+
+// subgoalView is the global level without one subgoal, for buildLevels.
+type subgoalView struct {
+	graph *SubgoalGraph
+	skip  int32
+}
+
+func (view *subgoalView) Neighbors(node int32, list []Edge[int32]) []Edge[int32] {
+	for _, v := range view.graph.globals[node] {
+		if v != view.skip {
+			list = append(list, Edge[int32]{Node: v, Cost: view.graph.cost(node, v)})
+		}
+	}
+
+	return list
+}
+
+func (view *subgoalView) Heuristic(node, goal int32) float64 {
+	return view.graph.cost(node, goal)
+}
+
+// subgoalQuery is the graph searched by FindPath, the subgoals plus the start
+// and end nodes numbered after them.
+type subgoalQuery struct {
+	graph      *SubgoalGraph
+	start      geo.Vec2[int64]
+	end        geo.Vec2[int64]
+	startLinks []int32
+	endLinks   map[int32]struct{}
+	endSide    map[int32]struct{}
+	direct     bool
+}
+
+func (query *subgoalQuery) startID() int32 {
+	return int32(len(query.graph.positions))
+}
+
+func (query *subgoalQuery) endID() int32 {
+	return int32(len(query.graph.positions)) + 1
+}
+
+func (query *subgoalQuery) position(node int32) geo.Vec2[int64] {
+	switch node {
+	case query.startID():
+		return query.start
+	case query.endID():
+		return query.end
+	}
+
+	return query.graph.positions[node]
+}
+
+func (query *subgoalQuery) Neighbors(node int32, list []Edge[int32]) []Edge[int32] {
+	graph := query.graph
+
+	switch node {
+	case query.endID():
+		return list
+
+	case query.startID():
+		if query.direct {
+			list = append(list, Edge[int32]{Node: query.endID(), Cost: HeuristicOctile(query.start, query.end)})
+		}
+
+		for _, v := range query.startLinks {
+			list = append(list, Edge[int32]{Node: v, Cost: HeuristicOctile(query.start, graph.positions[v])})
+		}
+
+		return list
+	}
+
+	if _, ok := query.endLinks[node]; ok {
+		list = append(list, Edge[int32]{Node: query.endID(), Cost: HeuristicOctile(graph.positions[node], query.end)})
+	}
+
+	if !graph.global[node] {
+		for _, v := range graph.edges[node] {
+			list = append(list, Edge[int32]{Node: v, Cost: graph.cost(node, v)})
+		}
+
+		return list
+	}
+
+	for _, v := range graph.globals[node] {
+		list = append(list, Edge[int32]{Node: v, Cost: graph.cost(node, v)})
+	}
+
+	for _, v := range graph.edges[node] {
+		if _, ok := query.endSide[v]; ok && !graph.global[v] {
+			list = append(list, Edge[int32]{Node: v, Cost: graph.cost(node, v)})
+		}
+	}
+
+	return list
+}
+
+func (query *subgoalQuery) Heuristic(node, goal int32) float64 {
+	return HeuristicOctile(query.position(node), query.end)
+}
+
+// FindPath returns the shortest MOVE_DIAG_NO_OBS path from start (excluded) to
+// end as straight and diagonal segments, end first like Finder.FindResult.
+func (graph *SubgoalGraph) FindPath(start, end geo.Vec2[int64]) (Result[geo.Vec2[int64]], error) {
+	result := Result[geo.Vec2[int64]]{}
+	if !graph.canWalk(start) || !graph.canWalk(end) {
+		return result, ErrUnreachable
+	}
+
+	if start == end {
+		return result, nil
+	}
+
+	query := graph.query
+	query.start, query.end = start, end
+	query.startLinks = query.startLinks[:0]
+	query.direct = false
+	query.endLinks = make(map[int32]struct{})
+	query.endSide = make(map[int32]struct{})
+
+	graph.scan(start, func(pos geo.Vec2[int64]) bool {
+		return pos == end || graph.isSubgoal(pos)
+	}, func(pos geo.Vec2[int64]) {
+		if pos == end {
+			query.direct = true
+		} else {
+			query.startLinks = append(query.startLinks, graph.ids[pos])
+		}
+	})
+
+	graph.scan(end, graph.isSubgoal, func(pos geo.Vec2[int64]) {
+		query.endLinks[graph.ids[pos]] = struct{}{}
+	})
+
+	if graph.isSubgoal(end) {
+		query.endLinks[graph.ids[end]] = struct{}{}
+	}
+
+	// local subgoals on the end side stay reachable from the global level
+	queue := make([]int32, 0)
+	for k := range query.endLinks {
+		if !graph.global[k] {
+			query.endSide[k] = struct{}{}
+			queue = append(queue, k)
+		}
+	}
+
+	for len(queue) > 0 {
+		node := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+
+		for _, v := range graph.edges[node] {
+			if _, ok := query.endSide[v]; !ok && !graph.global[v] {
+				query.endSide[v] = struct{}{}
+				queue = append(queue, v)
+			}
+		}
+	}
+
+	found, err := graph.finder.FindResult(query.startID(), query.endID(), GraphOptReversePath[int32](true))
+	result.Cost = found.Cost
+	result.Expanded = found.Expanded
+	if err != nil {
+		return result, err
+	}
+
+	prev := query.startID()
+	for _, v := range found.Path {
+		result.Path = graph.appendSegment(result.Path, query.position(prev), query.position(v), prev, v)
+		prev = v
+	}
+
+	slices.Reverse(result.Path)
+
+	return result, nil
+}
+
+// appendSegment appends the waypoints from one node to the next, start
+// excluded, expanding the bypass edges of the two level graph.
+func (graph *SubgoalGraph) appendSegment(list []geo.Vec2[int64], from, to geo.Vec2[int64], fromID, toID int32) []geo.Vec2[int64] {
+	if via, ok := graph.vias[edgeKey(fromID, toID)]; ok {
+		viaPos := graph.positions[via]
+		list = graph.appendSegment(list, from, viaPos, fromID, via)
+		return graph.appendSegment(list, viaPos, to, via, toID)
+	}
+
+	corner, ok := graph.diagonalFirst(from, to)
+	if !ok {
+		corner, _ = graph.diagonalFirst(to, from)
+	}
+
+	for _, v := range [2]geo.Vec2[int64]{corner, to} {
+		if v == from || len(list) > 0 && list[len(list)-1] == v {
+			continue
+		}
+
+		// merge collinear segments
+		if n := len(list); n > 0 {
+			prev := from
+			if n > 1 {
+				prev = list[n-2]
+			}
+
+			dx1, dy1 := dir(list[n-1], prev)
+			dx2, dy2 := dir(v, list[n-1])
+			if dx1 == dx2 && dy1 == dy2 {
+				list[n-1] = v
+				continue
+			}
+		}
+
+		list = append(list, v)
+	}
+
+	return list
+}
+
+// diagonalFirst returns the corner of the path from one cell to the other
+// that moves diagonally first, ok is false when it is blocked.
+func (graph *SubgoalGraph) diagonalFirst(from, to geo.Vec2[int64]) (geo.Vec2[int64], bool) {
+	dx, dy := dir(to, from)
+	steps := min(abs(to.X-from.X), abs(to.Y-from.Y))
+
+	cell := from
+	for i := int64(0); i < steps; i++ {
+		if !graph.canMoveDiagonal(cell, dx, dy) {
+			return cell, false
+		}
+
+		cell.X += dx
+		cell.Y += dy
+	}
+
+	corner := cell
+	for cell != to {
+		sx, sy := dir(to, cell)
+		cell.X += sx
+		cell.Y += sy
+		if !graph.canWalk(cell) {
+			return corner, false
+		}
+	}
+
+	return corner, true
+}
+
+type subgoalHeader struct {
+	Magic    [4]byte
+	Version  uint32
+	Width    int64
+	Height   int64
+	TwoLevel bool
+	Count    int32
+	Vias     int32
+}
+
+// Save writes the graph in a little endian binary format, LoadSubgoalGraph
+// reads it back over the same map.
+func (graph *SubgoalGraph) Save(w io.Writer) error {
+	header := subgoalHeader{
+		Magic:    subgoalMagic,
+		Version:  subgoal_version,
+		Width:    graph.width,
+		Height:   graph.height,
+		TwoLevel: graph.twoLevel,
+		Count:    int32(len(graph.positions)),
+		Vias:     int32(len(graph.vias)),
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, graph.positions); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, graph.global); err != nil {
+		return err
+	}
+
+	for i := range graph.positions {
+		for _, list := range [2][]int32{graph.edges[i], graph.globals[i]} {
+			if err := binary.Write(w, binary.LittleEndian, int32(len(list))); err != nil {
+				return err
+			}
+
+			if err := binary.Write(w, binary.LittleEndian, list); err != nil {
+				return err
+			}
+		}
+	}
+
+	// sorted so the same graph always gives the same bytes
+	vias := make([][3]int32, 0, len(graph.vias))
+	for k, v := range graph.vias {
+		vias = append(vias, [3]int32{k[0], k[1], v})
+	}
+
+	slices.SortFunc(vias, func(a, b [3]int32) int {
+		return slices.Compare(a[:], b[:])
+	})
+
+	return binary.Write(w, binary.LittleEndian, vias)
+}
+
+func LoadSubgoalGraph(r io.Reader, cellMap CellMap) (*SubgoalGraph, error) {
+	header := subgoalHeader{}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+
+	if header.Magic != subgoalMagic || header.Version != subgoal_version ||
+		header.Count < 0 || header.Vias < 0 || header.Width < 0 || header.Height < 0 ||
+		header.Width > subgoal_max_side || header.Height > subgoal_max_side ||
+		int64(header.Count) > header.Width*header.Height {
+		return nil, ErrSubgoalFormat
+	}
+
+	graph := newSubgoalGraph(cellMap, header.Width, header.Height, header.TwoLevel)
+
+	var err error
+	if graph.positions, err = readSlice[geo.Vec2[int64]](r, int64(header.Count)); err != nil {
+		return nil, err
+	}
+
+	if graph.global, err = readSlice[bool](r, int64(header.Count)); err != nil {
+		return nil, err
+	}
+
+	for i, v := range graph.positions {
+		graph.ids[v] = int32(i)
+	}
+
+	graph.edges = make([][]int32, header.Count)
+	graph.globals = make([][]int32, header.Count)
+
+	for i := range graph.positions {
+		for _, list := range [2]*[]int32{&graph.edges[i], &graph.globals[i]} {
+			var count int32
+			if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+				return nil, err
+			}
+
+			if count < 0 || count > header.Count {
+				return nil, ErrSubgoalFormat
+			}
+
+			if *list, err = readSlice[int32](r, int64(count)); err != nil {
+				return nil, err
+			}
+
+			for _, v := range *list {
+				if v < 0 || v >= header.Count {
+					return nil, ErrSubgoalFormat
+				}
+			}
+		}
+	}
+
+	vias, err := readSlice[[3]int32](r, int64(header.Vias))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range vias {
+		if min(v[0], v[1], v[2]) < 0 || max(v[0], v[1], v[2]) >= header.Count ||
+			v[0] == v[1] || v[2] == v[0] || v[2] == v[1] || graph.global[v[2]] {
+			return nil, ErrSubgoalFormat
+		}
+
+		graph.vias[edgeKey(v[0], v[1])] = v[2]
+	}
+
+	if !graph.checkVias() {
+		return nil, ErrSubgoalFormat
+	}
+
+	return graph, nil
+}
+
+// checkVias tells whether every bypass edge expands into real edges, both
+// halves of a via being an edge or a bypass edge without a cycle, so
+// appendSegment always ends.
+func (graph *SubgoalGraph) checkVias() bool {
+	const (
+		visiting = iota + 1
+		done
+	)
+
+	state := make(map[[2]int32]uint8, len(graph.vias))
+
+	var check func(key [2]int32) bool
+	check = func(key [2]int32) bool {
+		via, ok := graph.vias[key]
+		if !ok {
+			return slices.Contains(graph.edges[key[0]], key[1])
+		}
+
+		switch state[key] {
+		case visiting:
+			return false
+		case done:
+			return true
+		}
+
+		state[key] = visiting
+		if !check(edgeKey(key[0], via)) || !check(edgeKey(via, key[1])) {
+			return false
+		}
+
+		state[key] = done
+		return true
+	}
+
+	for k := range graph.vias {
+		if !check(k) {
+			return false
+		}
+	}
+
+	return true
+}
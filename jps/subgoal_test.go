@@ -0,0 +1,114 @@
+package jps
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSubgoalRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+
+	for round := 0; round < 20; round++ {
+		grid := randomGrid(rnd, 16+rnd.Int63n(16), 16+rnd.Int63n(16), 0.1+rnd.Float64()*0.3)
+
+		for _, twoLevel := range []bool{false, true} {
+			built := NewSubgoalGraph(grid, grid.Width(), grid.Height(), twoLevel)
+
+			buf := &bytes.Buffer{}
+			if err := built.Save(buf); err != nil {
+				t.Fatal(err)
+			}
+
+			graph, err := LoadSubgoalGraph(buf, grid)
+			if err != nil {
+				t.Fatalf("round %d two level %v: %v", round, twoLevel, err)
+			}
+
+			for i := 0; i < 10; i++ {
+				start, end := randomWalkable(rnd, grid), randomWalkable(rnd, grid)
+				dist := refDistances(grid, start, MOVE_DIAG_NO_OBS, getG)
+				want, reachable := dist[end]
+
+				result, err := graph.FindPath(start, end)
+				if !reachable {
+					if err == nil {
+						t.Errorf("two level %v %v -> %v: found a path to an unreachable end", twoLevel, start, end)
+					}
+
+					continue
+				}
+
+				if err != nil {
+					t.Errorf("two level %v %v -> %v: %v, want cost %v", twoLevel, start, end, err, want)
+					continue
+				}
+
+				if err := ValidatePath(grid, start, result.Path, MOVE_DIAG_NO_OBS); err != nil {
+					t.Errorf("two level %v %v -> %v: %v", twoLevel, start, end, err)
+				}
+
+				if length := PathLength(start, result.Path); math.Abs(length-want) > 1e-9 || math.Abs(result.Cost-want) > 1e-9 {
+					t.Errorf("two level %v %v -> %v: length %v cost %v, want %v", twoLevel, start, end, length, result.Cost, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSubgoalViaKeys(t *testing.T) {
+	rnd := rand.New(rand.NewSource(6))
+	grid := randomGrid(rnd, 32, 32, 0.2)
+	graph := NewSubgoalGraph(grid, grid.Width(), grid.Height(), true)
+	if len(graph.vias) == 0 {
+		t.Fatal("no bypass edges to test")
+	}
+
+	want := maps.Clone(graph.vias)
+
+	// a file may list the larger subgoal of an edge first
+	clear(graph.vias)
+	for k, v := range want {
+		graph.vias[[2]int32{k[1], k[0]}] = v
+	}
+
+	buf := &bytes.Buffer{}
+	if err := graph.Save(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadSubgoalGraph(buf, grid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !maps.Equal(loaded.vias, want) {
+		t.Errorf("loaded vias %v, want %v", loaded.vias, want)
+	}
+}
+
+func TestSubgoalViaCycle(t *testing.T) {
+	rnd := rand.New(rand.NewSource(7))
+	grid := randomGrid(rnd, 16, 16, 0.2)
+	graph := NewSubgoalGraph(grid, grid.Width(), grid.Height(), false)
+	if len(graph.positions) < 3 {
+		t.Fatal("too few subgoals to test")
+	}
+
+	// each via expands into the other, appendSegment would never end
+	graph.global[1], graph.global[2] = false, false
+	graph.vias[edgeKey(0, 1)] = 2
+	graph.vias[edgeKey(0, 2)] = 1
+
+	buf := &bytes.Buffer{}
+	if err := graph.Save(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadSubgoalGraph(buf, grid); !errors.Is(err, ErrSubgoalFormat) {
+		t.Errorf("via cycle: %v, want %v", err, ErrSubgoalFormat)
+	}
+}
@@ -0,0 +1,312 @@
+package jps
+
+import (
+	"math"
+	"slices"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+const (
+	tour_exact_size = 10
+	tour_epsilon    = 1e-9
+)
+
+// Tour visits the points in Order, indexes into the points given to FindTour.
+// Cost is the cost of Path. Unreachable lists the points start cannot reach,
+// the tour skips them.
+type Tour struct {
+	Order       []int
+	Path        []geo.Vec2[int64]
+	Cost        float64
+	Unreachable []int
+}
+
+// FindTour visits every point starting from start in the cheapest order it can
+// find. The order is exact for up to tour_exact_size points and a nearest
+// neighbour + 2-opt tour above that. Points start cannot reach are left out
+// and listed in Unreachable. The order is chosen on step costs and penalties,
+// FindOptTurnPenalty only shapes the final path.
+func (finder *Finder) FindTour(start geo.Vec2[int64], points []geo.Vec2[int64], options ...FindOption) (*Tour, error) {
+	tour := &Tour{Order: []int{}, Path: []geo.Vec2[int64]{}, Unreachable: []int{}}
+	if len(points) == 0 {
+		return tour, nil
+	}
+
+	finder.applyOptions(options)
+	costs := finder.tourCosts(start, points)
+
+	// the solvers only see the points start reaches
+	reachable := make([]int, 0, len(points))
+	for i := range points {
+		if math.IsInf(costs[0][i+1], 1) {
+			tour.Unreachable = append(tour.Unreachable, i)
+		} else {
+			reachable = append(reachable, i)
+		}
+	}
+
+	if len(reachable) == 0 {
+		return tour, nil
+	}
+
+	if len(tour.Unreachable) > 0 {
+		costs = subTourCosts(costs, reachable)
+	}
+
+	var order []int
+	if len(reachable) <= tour_exact_size {
+		order = solveTourExact(costs)
+	} else {
+		order = solveTourGreedy(costs)
+		improveTour(costs, order)
+	}
+
+	for i, v := range order {
+		order[i] = reachable[v]
+	}
+
+	waypoints := make([]geo.Vec2[int64], 0, len(order)-1)
+	for _, v := range order[:len(order)-1] {
+		waypoints = append(waypoints, points[v])
+	}
+
+	path, err := finder.FindVia(start, waypoints, points[order[len(order)-1]], options...)
+	if err != nil {
+		return nil, err
+	}
+
+	// the legs follow the finder heuristic, which may not find the paths
+	// the cost matrix was built from
+	tour.Order = order
+	tour.Path = path
+	tour.Cost = finder.pathCost
+
+	return tour, nil
+}
+
+var tourDirections = [8][2]int64{{0, -1}, {1, 0}, {0, 1}, {-1, 0}, {-1, -1}, {1, -1}, {1, 1}, {-1, 1}}
+
+// distances runs Dijkstra from start over the cells a query may enter and
+// calls visit with every cell in increasing cost until it returns false.
+func (finder *Finder) distances(start geo.Vec2[int64], visit func(pos geo.Vec2[int64], g float64) bool) {
+	cellMap := finder.cellMap
+	defer cellMap.Reset()
+
+	finder.startPos = start
+	finder.opens.Reset()
+	cellMap.SetState(start, CELL_STATE_OPEN)
+	finder.opens.Push(start, 0, 0)
+
+	for finder.opens.Len() > 0 {
+		pos, _ := finder.opens.Pop()
+		if cellMap.GetState(pos) == CELL_STATE_CLOSE {
+			continue
+		}
+
+		cellMap.SetState(pos, CELL_STATE_CLOSE)
+		g := cellMap.GetG(pos)
+		if !visit(pos, g) {
+			return
+		}
+
+		for _, d := range tourDirections {
+			v := geo.Vec2[int64]{X: pos.X + d[0], Y: pos.Y + d[1]}
+			state := cellMap.GetState(v)
+			if state == CELL_STATE_CLOSE || !finder.canStep(pos, v) {
+				continue
+			}
+
+			newG := g + finder.getStepCost(pos, v)
+			if state != CELL_STATE_OPEN || newG < cellMap.GetG(v) {
+				cellMap.SetG(v, newG)
+				cellMap.SetState(v, CELL_STATE_OPEN)
+				finder.opens.Push(v, newG, 0)
+			}
+		}
+	}
+}
+
+// tourCosts returns the matrix of path costs between start (index 0) and the
+// points (index i + 1), +Inf where there is no path. Every row is one
+// Dijkstra from its cell that stops once it has reached all points.
+func (finder *Finder) tourCosts(start geo.Vec2[int64], points []geo.Vec2[int64]) [][]float64 {
+	finder.nearest = false
+
+	nodes := make([]geo.Vec2[int64], 0, len(points)+1)
+	nodes = append(nodes, start)
+	nodes = append(nodes, points...)
+
+	targets := make(map[geo.Vec2[int64]][]int)
+	for i, v := range points {
+		targets[v] = append(targets[v], i+1)
+	}
+
+	costs := make([][]float64, len(nodes))
+
+	for i, from := range nodes {
+		costs[i] = make([]float64, len(nodes))
+		for j := 1; j < len(nodes); j++ {
+			if j != i {
+				costs[i][j] = math.Inf(1)
+			}
+		}
+
+		left := len(targets)
+		finder.distances(from, func(node geo.Vec2[int64], g float64) bool {
+			if indexes, ok := targets[node]; ok {
+				for _, v := range indexes {
+					costs[i][v] = g
+				}
+
+				left--
+			}
+
+			return left > 0
+		})
+	}
+
+	return costs
+}
+
+// subTourCosts keeps the rows and columns of start and the points given.
+func subTourCosts(costs [][]float64, points []int) [][]float64 {
+	keep := make([]int, 0, len(points)+1)
+	keep = append(keep, 0)
+	for _, v := range points {
+		keep = append(keep, v+1)
+	}
+
+	sub := make([][]float64, len(keep))
+	for i, row := range keep {
+		sub[i] = make([]float64, len(keep))
+		for j, column := range keep {
+			sub[i][j] = costs[row][column]
+		}
+	}
+
+	return sub
+}
+
+func getTourCost(costs [][]float64, order []int) float64 {
+	cost := 0.0
+	prev := 0
+
+	for _, v := range order {
+		cost += costs[prev][v+1]
+		prev = v + 1
+	}
+
+	return cost
+}
+
+func solveTourExact(costs [][]float64) []int {
+	n := len(costs) - 1
+	full := 1<<n - 1
+
+	// best[mask][i] is the cost of visiting mask, ending at point i
+	best := make([][]float64, full+1)
+	prev := make([][]int8, full+1)
+	for mask := range best {
+		best[mask] = make([]float64, n)
+		prev[mask] = make([]int8, n)
+		for i := range best[mask] {
+			best[mask][i] = math.Inf(1)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		best[1<<i][i] = costs[0][i+1]
+		prev[1<<i][i] = -1
+	}
+
+	for mask := 1; mask <= full; mask++ {
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) == 0 || math.IsInf(best[mask][i], 1) {
+				continue
+			}
+
+			for j := 0; j < n; j++ {
+				if mask&(1<<j) != 0 {
+					continue
+				}
+
+				next := mask | 1<<j
+				cost := best[mask][i] + costs[i+1][j+1]
+				if cost < best[next][j] {
+					best[next][j] = cost
+					prev[next][j] = int8(i)
+				}
+			}
+		}
+	}
+
+	last := 0
+	for i := 1; i < n; i++ {
+		if best[full][i] < best[full][last] {
+			last = i
+		}
+	}
+
+	if math.IsInf(best[full][last], 1) {
+		return solveTourGreedy(costs)
+	}
+
+	order := make([]int, 0, n)
+	for mask := full; last >= 0; {
+		order = append(order, last)
+		last, mask = int(prev[mask][last]), mask&^(1<<last)
+	}
+
+	slices.Reverse(order)
+	return order
+}
+
+func solveTourGreedy(costs [][]float64) []int {
+	n := len(costs) - 1
+	visited := make([]bool, n)
+	order := make([]int, 0, n)
+	cur := 0
+
+	for len(order) < n {
+		next := -1
+		for i := 0; i < n; i++ {
+			if visited[i] {
+				continue
+			}
+
+			if next < 0 || costs[cur][i+1] < costs[cur][next+1] {
+				next = i
+			}
+		}
+
+		visited[next] = true
+		order = append(order, next)
+		cur = next + 1
+	}
+
+	return order
+}
+
+// improveTour applies 2-opt moves until no reversal shortens the tour, the
+// whole tour is re-costed since paths are not always symmetric.
+func improveTour(costs [][]float64, order []int) {
+	best := getTourCost(costs, order)
+
+	for improved := true; improved; {
+		improved = false
+
+		for i := 0; i < len(order)-1; i++ {
+			for j := i + 1; j < len(order); j++ {
+				slices.Reverse(order[i : j+1])
+
+				if cost := getTourCost(costs, order); cost < best-tour_epsilon {
+					best = cost
+					improved = true
+				} else {
+					slices.Reverse(order[i : j+1])
+				}
+			}
+		}
+	}
+}
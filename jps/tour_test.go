@@ -0,0 +1,33 @@
+package jps
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+func TestTourCost(t *testing.T) {
+	rnd := rand.New(rand.NewSource(11))
+
+	for round := 0; round < 50; round++ {
+		grid := randomGrid(rnd, 24, 24, 0.25)
+		finder := NewFinder(grid, MOVE_DIAG_NO_OBS)
+
+		start := randomWalkable(rnd, grid)
+		points := make([]geo.Vec2[int64], 2+rnd.Intn(6))
+		for i := range points {
+			points[i] = randomWalkable(rnd, grid)
+		}
+
+		tour, err := finder.FindTour(start, points)
+		if err != nil {
+			t.Fatalf("round %d: %v", round, err)
+		}
+
+		if length := PathLength(start, tour.Path); math.Abs(length-tour.Cost) > 1e-9 {
+			t.Errorf("round %d: cost %v, path length %v", round, tour.Cost, length)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package jps
+
+import (
+	"math"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+// turnState is a cell entered with a heading, the goal state stands for the
+// end cell with any heading.
+type turnState struct {
+	pos  geo.Vec2[int64]
+	dx   int64
+	dy   int64
+	goal bool
+}
+
+type turnGraph struct {
+	finder *Finder
+	end    geo.Vec2[int64]
+}
+
+func (graph *turnGraph) Neighbors(state turnState, list []Edge[turnState]) []Edge[turnState] {
+	finder := graph.finder
+	if state.pos == graph.end {
+		list = append(list, Edge[turnState]{Node: turnState{pos: graph.end, goal: true}})
+	}
+
+	for _, v := range finder.findDefaultNeighbors(state.pos, MOVE_DIAG_ALWAYS) {
+		dx, dy := dir(v, state.pos)
+		cost := finder.getStepCost(state.pos, v) + finder.getTurnCost(state.dx, state.dy, dx, dy)
+		list = append(list, Edge[turnState]{Node: turnState{pos: v, dx: dx, dy: dy}, Cost: cost})
+	}
+
+	return list
+}
+
+func (graph *turnGraph) Heuristic(state, goal turnState) float64 {
+	if state.goal {
+		return 0
+	}
+
+	return graph.finder.heuristic(state.pos, graph.end)
+}
+
+// FindOptTurnPenalty charges penalty for every 45 degrees the heading turns,
+// a U turn costs four times the penalty. MOVE_ASTAR keeps the heading in the
+// search state and finds the cheapest path. The jump modes only charge the
+// turns between their jump points and keep one heading per cell, so they
+// prefer straighter paths without guaranteeing the cheapest one.
+func FindOptTurnPenalty(penalty float64) FindOption {
+	return func(finder *Finder) {
+		finder.turnPenalty = max(0, penalty)
+	}
+}
+
+// getTurnCost is the penalty for turning from the first heading to the
+// second one, no heading yet turns for free.
+func (finder *Finder) getTurnCost(dx1, dy1, dx2, dy2 int64) float64 {
+	if finder.turnPenalty <= 0 || dx1 == 0 && dy1 == 0 {
+		return 0
+	}
+
+	turn := abs(headingIndex(dx1, dy1) - headingIndex(dx2, dy2))
+	turn = min(turn, 8-turn)
+
+	if finder.costMode == COST_FIXED {
+		return float64(turn) * math.Round(finder.turnPenalty*FIXED_COST_STRAIGHT)
+	}
+
+	return float64(turn) * finder.turnPenalty
+}
+
+// headingIndex numbers the eight headings clockwise from up.
+func headingIndex(dx, dy int64) int64 {
+	switch {
+	case dx == 0 && dy < 0:
+		return 0
+	case dx > 0 && dy < 0:
+		return 1
+	case dx > 0 && dy == 0:
+		return 2
+	case dx > 0 && dy > 0:
+		return 3
+	case dx == 0 && dy > 0:
+		return 4
+	case dx < 0 && dy > 0:
+		return 5
+	case dx < 0 && dy == 0:
+		return 6
+	}
+
+	return 7
+}
+
+// searchTurns is the MOVE_ASTAR search over cells and headings, it appends
+// like search.
+func (finder *Finder) searchTurns(start, end geo.Vec2[int64], list []geo.Vec2[int64]) ([]geo.Vec2[int64], error) {
+	if finder.turns == nil {
+		finder.turns = NewGraphFinder[turnState](&turnGraph{finder: finder})
+	}
+
+	finder.turns.graph.(*turnGraph).end = end
+
+	options := []GraphOption[turnState]{GraphOptBudget[turnState](finder.budgetLimit)}
+	if len(finder.observers) > 0 {
+		options = append(options, GraphOptObserver(func(state turnState, g float64) {
+			for _, v := range finder.observers {
+				v.OnClose(state.pos, g)
+			}
+		}))
+	}
+
+	result, err := finder.turns.FindResult(turnState{pos: start}, turnState{pos: end, goal: true}, options...)
+	finder.budget.expanded = result.Expanded
+	if err != nil {
+		return list, err
+	}
+
+	finder.pathCost = result.Cost
+
+	// the goal state comes first and repeats the cell of the one after it
+	legStart := len(list)
+	for _, v := range result.Path[1:] {
+		list = append(list, v.pos)
+	}
+
+	if finder.trace != nil {
+		finder.trace.addPath(list[legStart:])
+	}
+
+	return list, nil
+}
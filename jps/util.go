@@ -1,8 +1,10 @@
 package jps
 
 import (
+	"encoding/binary"
+	"io"
 	"math"
-	
+
 	"github.com/xtxy/cxlib/geo"
 )
 
@@ -23,13 +25,51 @@ func clamp(a int64) int64 {
 	return 0
 }
 
+const (
+	FIXED_COST_STRAIGHT = 1000
+	FIXED_COST_DIAGONAL = 1414
+)
+
 func getG(pos1, pos2 geo.Vec2[int64]) float64 {
 	delta := pos1.Sub(pos2)
 	return delta.Len()
 }
 
-func getH(pos1, pos2 geo.Vec2[int64]) float64 {
-	return math.Abs(float64(pos1.X-pos2.X)) + math.Abs(float64(pos1.Y-pos2.Y))
+func getFixedG(pos1, pos2 geo.Vec2[int64]) float64 {
+	return float64(getFixedOctile(pos1, pos2))
+}
+
+func getFixedOctile(pos1, pos2 geo.Vec2[int64]) int64 {
+	dx, dy := abs(pos1.X-pos2.X), abs(pos1.Y-pos2.Y)
+	diagonal, straight := min(dx, dy), max(dx, dy)-min(dx, dy)
+
+	return straight*FIXED_COST_STRAIGHT + diagonal*FIXED_COST_DIAGONAL
+}
+
+func lessPos(pos1, pos2 geo.Vec2[int64]) bool {
+	return pos1.Y < pos2.Y || pos1.Y == pos2.Y && pos1.X < pos2.X
+}
+
+type Heuristic func(pos, end geo.Vec2[int64]) float64
+
+func HeuristicManhattan(pos, end geo.Vec2[int64]) float64 {
+	return math.Abs(float64(pos.X-end.X)) + math.Abs(float64(pos.Y-end.Y))
+}
+
+func HeuristicEuclidean(pos, end geo.Vec2[int64]) float64 {
+	return pos.Sub(end).Len()
+}
+
+// HeuristicFixedOctile is the octile distance in COST_FIXED units.
+func HeuristicFixedOctile(pos, end geo.Vec2[int64]) float64 {
+	return float64(getFixedOctile(pos, end))
+}
+
+func HeuristicOctile(pos, end geo.Vec2[int64]) float64 {
+	dx := math.Abs(float64(pos.X - end.X))
+	dy := math.Abs(float64(pos.Y - end.Y))
+
+	return dx + dy + (math.Sqrt2-2)*math.Min(dx, dy)
 }
 
 func jumpCanWalk(finder *Finder, pos geo.Vec2[int64], deltas [8]int64) bool {
@@ -41,3 +81,20 @@ func jumpCanWalk(finder *Finder, pos geo.Vec2[int64], deltas [8]int64) bool {
 	}
 	return false
 }
+
+// readSlice reads count little endian values in chunks, so a bad count in a
+// header fails at the end of the stream instead of allocating it up front.
+func readSlice[T any](r io.Reader, count int64) ([]T, error) {
+	const chunk = 1 << 16
+
+	values := make([]T, 0, min(count, chunk))
+	for int64(len(values)) < count {
+		start := len(values)
+		values = append(values, make([]T, min(count-int64(start), chunk))...)
+		if err := binary.Read(r, binary.LittleEndian, values[start:]); err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
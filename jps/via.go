@@ -0,0 +1,48 @@
+package jps
+
+import (
+	"slices"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+// FindVia finds a path from start through every waypoint in order to end.
+// Each waypoint appears once in the result, FindOptNearest only applies to
+// the last leg. A failing leg is reported as *LegError, leg 0 starts at start.
+func (finder *Finder) FindVia(start geo.Vec2[int64], waypoints []geo.Vec2[int64], end geo.Vec2[int64], options ...FindOption) ([]geo.Vec2[int64], error) {
+	finder.applyOptions(options)
+
+	nearest := finder.nearest
+	list := make([]geo.Vec2[int64], 0)
+	from := start
+	cost := 0.0
+
+	for i := 0; i <= len(waypoints); i++ {
+		to := end
+		finder.nearest = nearest
+		if i < len(waypoints) {
+			to = waypoints[i]
+			finder.nearest = false
+		}
+
+		legStart := len(list)
+		var err error
+		list, err = finder.search(from, to, list)
+		if err != nil {
+			return nil, &LegError{Leg: i, From: from, To: to, Err: err}
+		}
+
+		slices.Reverse(list[legStart:])
+		from = to
+		cost += finder.pathCost
+	}
+
+	finder.nearest = nearest
+	finder.pathCost = cost
+
+	if !finder.reversePath {
+		slices.Reverse(list)
+	}
+
+	return list, nil
+}
@@ -0,0 +1,381 @@
+package jps
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"math/bits"
+	"sort"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+const (
+	WALK_ENCODING_BITS uint8 = iota + 1
+	WALK_ENCODING_RLE
+)
+
+const walk_grid_version = 1
+
+// walk_grid_max_side keeps width * height of a loaded grid from overflowing
+const walk_grid_max_side = 1 << 30
+
+// walk_grid_max_cells keeps the (width + 1) * height run ends of an RLE grid
+// countable by its uint32 row offsets
+const walk_grid_max_cells = 1 << 31
+
+var walkGridMagic = [4]byte{'J', 'P', 'S', 'W'}
+
+// WalkGrid is the walkability of the cells [0, width) x [0, height) without
+// any search data. BitGrid and RLEGrid never change once loaded, so one
+// WalkGrid can back the StaticMap of every finder.
+type WalkGrid interface {
+	Width() int64
+	Height() int64
+	CanWalk(pos geo.Vec2[int64]) bool
+}
+
+// BitGrid keeps one bit per cell.
+type BitGrid struct {
+	width  int64
+	height int64
+	words  []uint64
+}
+
+// NewBitGrid returns a grid with every cell walkable.
+func NewBitGrid(width, height int64) *BitGrid {
+	grid := &BitGrid{width: width, height: height}
+	grid.words = make([]uint64, (width*height+63)/64)
+	for i := range grid.words {
+		grid.words[i] = ^uint64(0)
+	}
+
+	if len(grid.words) > 0 {
+		grid.words[len(grid.words)-1] &= grid.tailMask()
+	}
+
+	return grid
+}
+
+// tailMask keeps the bits of the last word that belong to cells, the padding
+// past width*height stays zero so Walkable counts cells only.
+func (grid *BitGrid) tailMask() uint64 {
+	if rest := grid.width * grid.height % 64; rest != 0 {
+		return 1<<rest - 1
+	}
+
+	return ^uint64(0)
+}
+
+// ToBitGrid copies the walkability of the cells [0, width) x [0, height).
+func ToBitGrid(cellMap CellMap, width, height int64) *BitGrid {
+	grid := &BitGrid{width: width, height: height}
+	grid.words = make([]uint64, (width*height+63)/64)
+
+	pos := geo.Vec2[int64]{}
+	for pos.Y = 0; pos.Y < height; pos.Y++ {
+		for pos.X = 0; pos.X < width; pos.X++ {
+			if cellMap.CanWalk(pos) {
+				i := pos.Y*width + pos.X
+				grid.words[i/64] |= 1 << (i % 64)
+			}
+		}
+	}
+
+	return grid
+}
+
+func (grid *BitGrid) Width() int64 {
+	return grid.width
+}
+
+func (grid *BitGrid) Height() int64 {
+	return grid.height
+}
+
+func (grid *BitGrid) CanWalk(pos geo.Vec2[int64]) bool {
+	if pos.X < 0 || pos.Y < 0 || pos.X >= grid.width || pos.Y >= grid.height {
+		return false
+	}
+
+	i := pos.Y*grid.width + pos.X
+	return grid.words[i/64]&(1<<(i%64)) != 0
+}
+
+// SetWalkable changes a cell, do not call it once the grid is shared.
+func (grid *BitGrid) SetWalkable(pos geo.Vec2[int64], walkable bool) {
+	if pos.X < 0 || pos.Y < 0 || pos.X >= grid.width || pos.Y >= grid.height {
+		return
+	}
+
+	i := pos.Y*grid.width + pos.X
+	if walkable {
+		grid.words[i/64] |= 1 << (i % 64)
+	} else {
+		grid.words[i/64] &^= 1 << (i % 64)
+	}
+}
+
+// Walkable counts the walkable cells.
+func (grid *BitGrid) Walkable() int64 {
+	var count int
+	for _, v := range grid.words {
+		count += bits.OnesCount64(v)
+	}
+
+	return int64(count)
+}
+
+// RLEGrid keeps every row as runs alternating between blocked and walkable
+// cells, the first run is blocked and may be empty. It suits maps with large
+// open or solid areas, a lookup costs a binary search in the row.
+type RLEGrid struct {
+	width  int64
+	height int64
+	rows   []uint32
+	ends   []uint32
+}
+
+// ToRLEGrid encodes the walkability of the cells [0, width) x [0, height),
+// width * height must not exceed walk_grid_max_cells to load again.
+func ToRLEGrid(cellMap CellMap, width, height int64) *RLEGrid {
+	grid := &RLEGrid{width: width, height: height}
+	grid.rows = make([]uint32, 0, height+1)
+	grid.ends = make([]uint32, 0)
+
+	pos := geo.Vec2[int64]{}
+	for pos.Y = 0; pos.Y < height; pos.Y++ {
+		grid.rows = append(grid.rows, uint32(len(grid.ends)))
+
+		walkable := false
+		for pos.X = 0; pos.X < width; pos.X++ {
+			if cellMap.CanWalk(pos) != walkable {
+				grid.ends = append(grid.ends, uint32(pos.X))
+				walkable = !walkable
+			}
+		}
+
+		grid.ends = append(grid.ends, uint32(width))
+	}
+
+	grid.rows = append(grid.rows, uint32(len(grid.ends)))
+
+	return grid
+}
+
+func (grid *RLEGrid) Width() int64 {
+	return grid.width
+}
+
+func (grid *RLEGrid) Height() int64 {
+	return grid.height
+}
+
+func (grid *RLEGrid) CanWalk(pos geo.Vec2[int64]) bool {
+	if pos.X < 0 || pos.Y < 0 || pos.X >= grid.width || pos.Y >= grid.height {
+		return false
+	}
+
+	runs := grid.ends[grid.rows[pos.Y]:grid.rows[pos.Y+1]]
+	i := sort.Search(len(runs), func(i int) bool {
+		return int64(runs[i]) > pos.X
+	})
+
+	return i%2 == 1
+}
+
+// Runs counts the runs of all rows.
+func (grid *RLEGrid) Runs() int {
+	return len(grid.ends)
+}
+
+// StaticMap is a CellMap over a WalkGrid with its own search data. Give every
+// finder its own StaticMap over one shared WalkGrid.
+type StaticMap struct {
+	grid WalkGrid
+
+	gridSearch
+}
+
+func NewStaticMap(grid WalkGrid) *StaticMap {
+	return &StaticMap{
+		grid:       grid,
+		gridSearch: newGridSearch(grid.Width(), grid.Height()),
+	}
+}
+
+func (static *StaticMap) Grid() WalkGrid {
+	return static.grid
+}
+
+func (static *StaticMap) CanWalk(pos geo.Vec2[int64]) bool {
+	return static.grid.CanWalk(pos)
+}
+
+// ToGridMap copies the walkability of grid into a new GridMap.
+func ToGridMap(grid WalkGrid) *GridMap {
+	gridMap := NewGridMap(grid.Width(), grid.Height())
+
+	pos := geo.Vec2[int64]{}
+	for pos.Y = 0; pos.Y < grid.Height(); pos.Y++ {
+		for pos.X = 0; pos.X < grid.Width(); pos.X++ {
+			if !grid.CanWalk(pos) {
+				gridMap.SetWalkable(pos, false)
+			}
+		}
+	}
+
+	return gridMap
+}
+
+type walkGridHeader struct {
+	Magic    [4]byte
+	Version  uint32
+	Encoding uint8
+	Width    int64
+	Height   int64
+	Count    int64
+}
+
+// Save writes the grid in the little endian walk grid format, a header, the
+// words and a CRC32 of both.
+func (grid *BitGrid) Save(w io.Writer) error {
+	return saveWalkGrid(w, WALK_ENCODING_BITS, grid.width, grid.height, int64(len(grid.words)), grid.words)
+}
+
+// Save writes the grid in the little endian walk grid format, a header, the
+// row offsets and run ends and a CRC32 of all of them.
+func (grid *RLEGrid) Save(w io.Writer) error {
+	return saveWalkGrid(w, WALK_ENCODING_RLE, grid.width, grid.height, int64(len(grid.ends)), grid.rows, grid.ends)
+}
+
+func saveWalkGrid(w io.Writer, encoding uint8, width, height, count int64, payload ...any) error {
+	hash := crc32.NewIEEE()
+	out := io.MultiWriter(w, hash)
+
+	header := walkGridHeader{
+		Magic:    walkGridMagic,
+		Version:  walk_grid_version,
+		Encoding: encoding,
+		Width:    width,
+		Height:   height,
+		Count:    count,
+	}
+
+	if err := binary.Write(out, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+
+	for _, v := range payload {
+		if err := binary.Write(out, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	return binary.Write(w, binary.LittleEndian, hash.Sum32())
+}
+
+// LoadWalkGrid reads a grid written by BitGrid.Save or RLEGrid.Save and
+// returns it as it was saved.
+func LoadWalkGrid(r io.Reader) (WalkGrid, error) {
+	hash := crc32.NewIEEE()
+	in := io.TeeReader(r, hash)
+
+	header := walkGridHeader{}
+	if err := binary.Read(in, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+
+	if header.Magic != walkGridMagic || header.Version != walk_grid_version ||
+		header.Width < 0 || header.Height < 0 || header.Count < 0 ||
+		header.Width > walk_grid_max_side || header.Height > walk_grid_max_side ||
+		header.Width*header.Height > walk_grid_max_cells {
+		return nil, ErrWalkGridFormat
+	}
+
+	var grid WalkGrid
+	var err error
+
+	switch header.Encoding {
+	case WALK_ENCODING_BITS:
+		grid, err = loadBitGrid(in, header)
+	case WALK_ENCODING_RLE:
+		grid, err = loadRLEGrid(in, header)
+	default:
+		return nil, ErrWalkGridFormat
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	sum := hash.Sum32()
+
+	var checksum uint32
+	if err := binary.Read(r, binary.LittleEndian, &checksum); err != nil {
+		return nil, err
+	}
+
+	if checksum != sum {
+		return nil, ErrWalkGridChecksum
+	}
+
+	return grid, nil
+}
+
+func loadBitGrid(r io.Reader, header walkGridHeader) (*BitGrid, error) {
+	if header.Count != (header.Width*header.Height+63)/64 {
+		return nil, ErrWalkGridFormat
+	}
+
+	words, err := readSlice[uint64](r, header.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	grid := &BitGrid{width: header.Width, height: header.Height, words: words}
+	if len(words) > 0 && words[len(words)-1]&^grid.tailMask() != 0 {
+		return nil, ErrWalkGridFormat
+	}
+
+	return grid, nil
+}
+
+func loadRLEGrid(r io.Reader, header walkGridHeader) (*RLEGrid, error) {
+	// every row ends with one run at least
+	if header.Count < header.Height || header.Count > (header.Width+1)*header.Height {
+		return nil, ErrWalkGridFormat
+	}
+
+	values, err := readSlice[uint32](r, header.Height+1+header.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	grid := &RLEGrid{width: header.Width, height: header.Height}
+	grid.rows = values[:header.Height+1]
+	grid.ends = values[header.Height+1:]
+
+	if grid.rows[0] != 0 || int64(grid.rows[header.Height]) != header.Count {
+		return nil, ErrWalkGridFormat
+	}
+
+	for y := int64(0); y < header.Height; y++ {
+		if grid.rows[y] >= grid.rows[y+1] || int64(grid.rows[y+1]) > header.Count {
+			return nil, ErrWalkGridFormat
+		}
+
+		runs := grid.ends[grid.rows[y]:grid.rows[y+1]]
+		if int64(runs[len(runs)-1]) != header.Width {
+			return nil, ErrWalkGridFormat
+		}
+
+		for i := 1; i < len(runs); i++ {
+			if runs[i] <= runs[i-1] {
+				return nil, ErrWalkGridFormat
+			}
+		}
+	}
+
+	return grid, nil
+}
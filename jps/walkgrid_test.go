@@ -0,0 +1,139 @@
+package jps
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+func TestNewBitGridWalkable(t *testing.T) {
+	for _, size := range []geo.Vec2[int64]{{X: 3, Y: 3}, {X: 8, Y: 8}, {X: 13, Y: 7}, {X: 0, Y: 5}} {
+		if walkable := NewBitGrid(size.X, size.Y).Walkable(); walkable != size.X*size.Y {
+			t.Errorf("%v: %d walkable cells, want %d", size, walkable, size.X*size.Y)
+		}
+	}
+}
+
+// checkWalkGrid compares every cell of grid with gridMap.
+func checkWalkGrid(t *testing.T, name string, grid WalkGrid, gridMap *GridMap) {
+	t.Helper()
+
+	if grid.Width() != gridMap.Width() || grid.Height() != gridMap.Height() {
+		t.Fatalf("%s: size %dx%d, want %dx%d", name, grid.Width(), grid.Height(), gridMap.Width(), gridMap.Height())
+	}
+
+	pos := geo.Vec2[int64]{}
+	for pos.Y = 0; pos.Y < gridMap.Height(); pos.Y++ {
+		for pos.X = 0; pos.X < gridMap.Width(); pos.X++ {
+			if grid.CanWalk(pos) != gridMap.CanWalk(pos) {
+				t.Fatalf("%s: cell %v walkable %v, want %v", name, pos, grid.CanWalk(pos), gridMap.CanWalk(pos))
+			}
+		}
+	}
+}
+
+func TestWalkGridRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+
+	for round := 0; round < 20; round++ {
+		width, height := 1+rnd.Int63n(70), 1+rnd.Int63n(40)
+		gridMap := randomGrid(rnd, width, height, rnd.Float64()*0.6)
+
+		var walkable int64
+		pos := geo.Vec2[int64]{}
+		for pos.Y = 0; pos.Y < height; pos.Y++ {
+			for pos.X = 0; pos.X < width; pos.X++ {
+				if gridMap.CanWalk(pos) {
+					walkable++
+				}
+			}
+		}
+
+		bitGrid := ToBitGrid(gridMap, width, height)
+		rleGrid := ToRLEGrid(gridMap, width, height)
+
+		buf := &bytes.Buffer{}
+		if err := bitGrid.Save(buf); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := LoadWalkGrid(buf)
+		if err != nil {
+			t.Fatalf("round %d bits: %v", round, err)
+		}
+
+		loadedBits, ok := loaded.(*BitGrid)
+		if !ok {
+			t.Fatalf("round %d bits: loaded %T", round, loaded)
+		}
+
+		if loadedBits.Walkable() != walkable {
+			t.Errorf("round %d bits: %d walkable cells, want %d", round, loadedBits.Walkable(), walkable)
+		}
+
+		checkWalkGrid(t, "bits", loadedBits, gridMap)
+
+		buf.Reset()
+		if err := rleGrid.Save(buf); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err = LoadWalkGrid(buf)
+		if err != nil {
+			t.Fatalf("round %d rle: %v", round, err)
+		}
+
+		loadedRLE, ok := loaded.(*RLEGrid)
+		if !ok {
+			t.Fatalf("round %d rle: loaded %T", round, loaded)
+		}
+
+		if loadedRLE.Runs() != rleGrid.Runs() {
+			t.Errorf("round %d rle: %d runs, want %d", round, loadedRLE.Runs(), rleGrid.Runs())
+		}
+
+		checkWalkGrid(t, "rle", loadedRLE, gridMap)
+	}
+}
+
+func TestWalkGridCorrupt(t *testing.T) {
+	grid := NewBitGrid(3, 3)
+
+	buf := &bytes.Buffer{}
+	if err := grid.Save(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// flip a bit of the payload, the checksum must catch it
+	data := bytes.Clone(buf.Bytes())
+	data[len(data)-12] ^= 1
+	if _, err := LoadWalkGrid(bytes.NewReader(data)); !errors.Is(err, ErrWalkGridChecksum) {
+		t.Errorf("flipped payload: %v, want %v", err, ErrWalkGridChecksum)
+	}
+
+	// a padding bit past the 9 cells is a format error even if it was saved
+	grid.words[0] |= 1 << 20
+	buf.Reset()
+	if err := grid.Save(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadWalkGrid(buf); !errors.Is(err, ErrWalkGridFormat) {
+		t.Errorf("padding bit: %v, want %v", err, ErrWalkGridFormat)
+	}
+}
+
+func TestWalkGridTooLarge(t *testing.T) {
+	// the run ends of this grid would overflow the uint32 row offsets
+	buf := &bytes.Buffer{}
+	if err := saveWalkGrid(buf, WALK_ENCODING_RLE, 1<<20, 1<<12, 1<<12, []uint32{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadWalkGrid(buf); !errors.Is(err, ErrWalkGridFormat) {
+		t.Errorf("oversized grid: %v, want %v", err, ErrWalkGridFormat)
+	}
+}
@@ -0,0 +1,92 @@
+package mapgen
+
+import "github.com/xtxy/cxlib/geo"
+
+// BSP splits the map into leaves until they get too small or deep, puts a
+// room in every leaf and joins sibling subtrees with corridors. A map too
+// small for GenOptMinRoom gets one room as large as it allows.
+func BSP(width, height, seed int64, options ...GenOption) *Level {
+	cfg := newConfig(options)
+	gen := newGenerator(width, height, seed)
+
+	// leaves cover the cells inside the border
+	leaf := geo.Rect[int64]{X: 1, Y: 1, Width: width - 3, Height: height - 3}
+	if leaf.Width >= 0 && leaf.Height >= 0 {
+		gen.split(cfg, leaf, 0)
+	}
+
+	return gen.finish()
+}
+
+// split digs the rooms of leaf and returns a floor cell of one of them. Leaves
+// and rooms contain [X, X+Width] x [Y, Y+Height] like geo.Rect.Contain.
+func (gen *generator) split(cfg *config, leaf geo.Rect[int64], depth int) geo.Vec2[int64] {
+	cellsX, cellsY := leaf.Width+1, leaf.Height+1
+
+	// a leaf splits when both halves can hold a room and a wall between them
+	minLeaf := cfg.minRoom + 1
+	canX := cellsX >= minLeaf*2
+	canY := cellsY >= minLeaf*2
+
+	if depth < cfg.maxDepth && (canX || canY) {
+		vertical := canX
+		if canX && canY {
+			vertical = cellsX > cellsY || cellsX == cellsY && gen.rnd.Intn(2) == 0
+		}
+
+		first, second := leaf, leaf
+		if vertical {
+			at := minLeaf + gen.rnd.Int63n(cellsX-minLeaf*2+1)
+			first.Width = at - 1
+			second.X += at
+			second.Width -= at
+		} else {
+			at := minLeaf + gen.rnd.Int63n(cellsY-minLeaf*2+1)
+			first.Height = at - 1
+			second.Y += at
+			second.Height -= at
+		}
+
+		from := gen.split(cfg, first, depth+1)
+		to := gen.split(cfg, second, depth+1)
+		gen.digLine(from, to)
+
+		if gen.rnd.Intn(2) == 0 {
+			return from
+		}
+
+		return to
+	}
+
+	// the room keeps one cell of the leaf free on the right and bottom so
+	// rooms of neighbor leaves never touch, unless the leaf is too small
+	roomSize := func(side int64) (int64, int64) {
+		limit := side - 1
+		if limit < cfg.minRoom {
+			limit = side
+		}
+
+		lower := min(cfg.minRoom, limit)
+		size := lower + gen.rnd.Int63n(limit-lower+1)
+
+		return size, gen.rnd.Int63n(limit - size + 1)
+	}
+
+	roomWidth, offsetX := roomSize(cellsX)
+	roomHeight, offsetY := roomSize(cellsY)
+	room := geo.Rect[int64]{X: leaf.X + offsetX, Y: leaf.Y + offsetY, Width: roomWidth - 1, Height: roomHeight - 1}
+
+	pos := geo.Vec2[int64]{}
+	for pos.Y = room.Y; pos.Y < room.Y+roomHeight; pos.Y++ {
+		for pos.X = room.X; pos.X < room.X+roomWidth; pos.X++ {
+			gen.dig(pos)
+		}
+	}
+
+	gen.level.Rooms = append(gen.level.Rooms, room)
+
+	return geo.Vec2[int64]{
+		X: room.X + gen.rnd.Int63n(roomWidth),
+		Y: room.Y + gen.rnd.Int63n(roomHeight),
+	}
+}
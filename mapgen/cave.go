@@ -0,0 +1,56 @@
+package mapgen
+
+import "github.com/xtxy/cxlib/geo"
+
+// Caves blocks a random share of the cells and smooths them with a cellular
+// automaton: a cell with more than four blocked neighbors gets blocked, one
+// with less than four gets dug. The border counts as blocked.
+func Caves(width, height, seed int64, options ...GenOption) *Level {
+	cfg := newConfig(options)
+	gen := newGenerator(width, height, seed)
+
+	pos := geo.Vec2[int64]{}
+	for pos.Y = 0; pos.Y < height; pos.Y++ {
+		for pos.X = 0; pos.X < width; pos.X++ {
+			if gen.rnd.Float64() >= cfg.fill {
+				gen.dig(pos)
+			}
+		}
+	}
+
+	next := make([]bool, len(gen.cells))
+	for i := 0; i < cfg.iterations; i++ {
+		for pos.Y = 0; pos.Y < height; pos.Y++ {
+			for pos.X = 0; pos.X < width; pos.X++ {
+				offset := pos.Y*width + pos.X
+				walls := gen.countWalls(pos)
+
+				switch {
+				case !gen.inner(pos) || walls > 4:
+					next[offset] = false
+				case walls < 4:
+					next[offset] = true
+				default:
+					next[offset] = gen.cells[offset]
+				}
+			}
+		}
+
+		gen.cells, next = next, gen.cells
+	}
+
+	return gen.finish()
+}
+
+func (gen *generator) countWalls(pos geo.Vec2[int64]) int {
+	var walls int
+	for dy := int64(-1); dy <= 1; dy++ {
+		for dx := int64(-1); dx <= 1; dx++ {
+			if (dx != 0 || dy != 0) && !gen.isFloor(geo.Vec2[int64]{X: pos.X + dx, Y: pos.Y + dy}) {
+				walls++
+			}
+		}
+	}
+
+	return walls
+}
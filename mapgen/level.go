@@ -0,0 +1,238 @@
+package mapgen
+
+import (
+	"math/rand"
+
+	"github.com/xtxy/cxlib/geo"
+	"github.com/xtxy/cxlib/jps"
+)
+
+// Level is a generated map. The border cells are always blocked and every
+// walkable cell reaches every other one with straight steps, so any move
+// mode can path between them. Rooms are the rooms of BSP levels, a room
+// covers [X, X+Width] x [Y, Y+Height], the cells geo.Rect.Contain takes in.
+type Level struct {
+	Map    *jps.GridMap
+	Width  int64
+	Height int64
+	Seed   int64
+	Rooms  []geo.Rect[int64]
+}
+
+type config struct {
+	minRoom    int64
+	maxDepth   int
+	fill       float64
+	iterations int
+	floor      float64
+}
+
+type GenOption func(cfg *config)
+
+// GenOptMinRoom sets the smallest room side of BSP, 4 by default.
+func GenOptMinRoom(size int64) GenOption {
+	return func(cfg *config) {
+		cfg.minRoom = max(size, 1)
+	}
+}
+
+// GenOptMaxDepth limits how often BSP splits the map, 8 by default.
+func GenOptMaxDepth(depth int) GenOption {
+	return func(cfg *config) {
+		cfg.maxDepth = depth
+	}
+}
+
+// GenOptFill sets the share of cells Caves starts blocked, 0.45 by default.
+func GenOptFill(ratio float64) GenOption {
+	return func(cfg *config) {
+		cfg.fill = ratio
+	}
+}
+
+// GenOptIterations sets how often Caves smooths the map, 5 by default.
+func GenOptIterations(iterations int) GenOption {
+	return func(cfg *config) {
+		cfg.iterations = iterations
+	}
+}
+
+// GenOptFloor sets the share of cells DrunkardWalk digs, 0.4 by default.
+func GenOptFloor(ratio float64) GenOption {
+	return func(cfg *config) {
+		cfg.floor = min(max(ratio, 0), 1)
+	}
+}
+
+func newConfig(options []GenOption) *config {
+	cfg := &config{
+		minRoom:    4,
+		maxDepth:   8,
+		fill:       0.45,
+		iterations: 5,
+		floor:      0.4,
+	}
+
+	for _, v := range options {
+		v(cfg)
+	}
+
+	return cfg
+}
+
+// generator digs a level into a map that starts blocked.
+type generator struct {
+	level *Level
+	rnd   *rand.Rand
+	cells []bool
+}
+
+func newGenerator(width, height, seed int64) *generator {
+	gen := &generator{
+		level: &Level{
+			Map:    jps.NewGridMap(width, height),
+			Width:  width,
+			Height: height,
+			Seed:   seed,
+		},
+		rnd:   rand.New(rand.NewSource(seed)),
+		cells: make([]bool, width*height),
+	}
+
+	return gen
+}
+
+// inner tells whether pos is inside the border.
+func (gen *generator) inner(pos geo.Vec2[int64]) bool {
+	return pos.X > 0 && pos.Y > 0 && pos.X < gen.level.Width-1 && pos.Y < gen.level.Height-1
+}
+
+func (gen *generator) dig(pos geo.Vec2[int64]) {
+	if gen.inner(pos) {
+		gen.cells[pos.Y*gen.level.Width+pos.X] = true
+	}
+}
+
+func (gen *generator) isFloor(pos geo.Vec2[int64]) bool {
+	return gen.inner(pos) && gen.cells[pos.Y*gen.level.Width+pos.X]
+}
+
+// digLine digs a corridor from one cell to the other, first along x or y at
+// random and then along the other axis.
+func (gen *generator) digLine(from, to geo.Vec2[int64]) {
+	corner := geo.Vec2[int64]{X: to.X, Y: from.Y}
+	if gen.rnd.Intn(2) == 0 {
+		corner = geo.Vec2[int64]{X: from.X, Y: to.Y}
+	}
+
+	for _, v := range [2][2]geo.Vec2[int64]{{from, corner}, {corner, to}} {
+		pos := v[0]
+		gen.dig(pos)
+		for pos != v[1] {
+			pos.X += sign(v[1].X - pos.X)
+			pos.Y += sign(v[1].Y - pos.Y)
+			gen.dig(pos)
+		}
+	}
+}
+
+// connect joins every region of floor to the first one by digging the
+// shortest straight step tunnel from it.
+func (gen *generator) connect() {
+	width, height := gen.level.Width, gen.level.Height
+	regions := make([]int32, width*height)
+
+	var count int32
+	for i, v := range gen.cells {
+		if !v || regions[i] != 0 {
+			continue
+		}
+
+		count++
+		region := gen.flood(int64(i), regions, count)
+		if count == 1 {
+			continue
+		}
+
+		// breadth first search through any cell from the region until it
+		// meets the first region, then dig back along the parents
+		parents := map[int64]int64{}
+		queue := region
+		for _, cell := range region {
+			parents[cell] = -1
+		}
+
+		for k := 0; k < len(queue); k++ {
+			cell := queue[k]
+			if regions[cell] == 1 {
+				for ; cell >= 0; cell = parents[cell] {
+					gen.cells[cell] = true
+				}
+
+				break
+			}
+
+			pos := geo.Vec2[int64]{X: cell % width, Y: cell / width}
+			for _, d := range [4][2]int64{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+				next := geo.Vec2[int64]{X: pos.X + d[0], Y: pos.Y + d[1]}
+				offset := next.Y*width + next.X
+				if _, ok := parents[offset]; ok || !gen.inner(next) {
+					continue
+				}
+
+				parents[offset] = cell
+				queue = append(queue, offset)
+			}
+		}
+
+		// the tunnel joins this region and the cells it dug to the first one
+		gen.flood(int64(i), regions, 1)
+	}
+}
+
+// flood labels the 4-connected floor cells around start with region and
+// returns them.
+func (gen *generator) flood(start int64, regions []int32, region int32) []int64 {
+	width := gen.level.Width
+	cells := []int64{start}
+	regions[start] = region
+
+	for k := 0; k < len(cells); k++ {
+		pos := geo.Vec2[int64]{X: cells[k] % width, Y: cells[k] / width}
+		for _, d := range [4][2]int64{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+			next := geo.Vec2[int64]{X: pos.X + d[0], Y: pos.Y + d[1]}
+			offset := next.Y*width + next.X
+			if gen.isFloor(next) && regions[offset] != region {
+				regions[offset] = region
+				cells = append(cells, offset)
+			}
+		}
+	}
+
+	return cells
+}
+
+// finish connects the floor and writes it to the map.
+func (gen *generator) finish() *Level {
+	gen.connect()
+
+	pos := geo.Vec2[int64]{}
+	for pos.Y = 0; pos.Y < gen.level.Height; pos.Y++ {
+		for pos.X = 0; pos.X < gen.level.Width; pos.X++ {
+			gen.level.Map.SetWalkable(pos, gen.isFloor(pos))
+		}
+	}
+
+	return gen.level
+}
+
+func sign(a int64) int64 {
+	switch {
+	case a > 0:
+		return 1
+	case a < 0:
+		return -1
+	}
+
+	return 0
+}
@@ -0,0 +1,37 @@
+package mapgen
+
+import "github.com/xtxy/cxlib/geo"
+
+// DrunkardWalk digs from the centre with random straight steps until the
+// floor share of the cells inside the border is reached.
+func DrunkardWalk(width, height, seed int64, options ...GenOption) *Level {
+	cfg := newConfig(options)
+	gen := newGenerator(width, height, seed)
+
+	inner := max(width-2, 0) * max(height-2, 0)
+	if inner == 0 {
+		return gen.finish()
+	}
+
+	target := max(int64(float64(inner)*cfg.floor), 1)
+	pos := geo.Vec2[int64]{X: width / 2, Y: height / 2}
+	gen.dig(pos)
+	dug := int64(1)
+
+	// the step limit ends the walk on maps where the target cannot be met
+	for steps := inner * 100; dug < target && steps > 0; steps-- {
+		d := [4][2]int64{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}[gen.rnd.Intn(4)]
+		next := geo.Vec2[int64]{X: pos.X + d[0], Y: pos.Y + d[1]}
+		if !gen.inner(next) {
+			continue
+		}
+
+		pos = next
+		if !gen.isFloor(pos) {
+			gen.dig(pos)
+			dug++
+		}
+	}
+
+	return gen.finish()
+}
@@ -0,0 +1,334 @@
+package nav
+
+import (
+	"errors"
+	"math"
+	"slices"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+const epsilon = 1e-9
+
+var (
+	ErrBadPolygon  = errors.New("nav: polygon needs at least three vertices and a non zero area")
+	ErrTriangulate = errors.New("nav: polygon cannot be triangulated, check for self intersections")
+	ErrOutside     = errors.New("nav: point outside the mesh")
+	ErrUnreachable = errors.New("nav: end point unreachable")
+)
+
+// Triangle edge i runs from Vertices[i] to Vertices[(i+1)%3], Neighbors[i] is
+// the triangle across it or -1. Vertices are in counter clockwise order.
+type Triangle struct {
+	Vertices  [3]int
+	Neighbors [3]int
+}
+
+type Mesh struct {
+	Vertices  []geo.Vec2[float64]
+	Triangles []Triangle
+}
+
+// NewMesh triangulates the walkable area inside outline and outside every
+// hole. Polygons may be given in either winding, holes must lie inside the
+// outline and must not touch each other.
+func NewMesh(outline []geo.Vec2[float64], holes ...[]geo.Vec2[float64]) (*Mesh, error) {
+	mesh := new(Mesh)
+
+	poly, err := mesh.addPolygon(outline, true)
+	if err != nil {
+		return nil, err
+	}
+
+	holeIndexes := make([][]int, 0, len(holes))
+	for _, v := range holes {
+		hole, err := mesh.addPolygon(v, false)
+		if err != nil {
+			return nil, err
+		}
+
+		holeIndexes = append(holeIndexes, hole)
+	}
+
+	// bridge the holes from right to left so every bridge stays visible
+	slices.SortFunc(holeIndexes, func(a, b []int) int {
+		return -compareFloat(mesh.maxX(a), mesh.maxX(b))
+	})
+
+	for _, v := range holeIndexes {
+		poly = mesh.bridgeHole(poly, v)
+	}
+
+	if err := mesh.triangulate(poly); err != nil {
+		return nil, err
+	}
+
+	mesh.link()
+
+	return mesh, nil
+}
+
+func (mesh *Mesh) addPolygon(polygon []geo.Vec2[float64], ccw bool) ([]int, error) {
+	if len(polygon) < 3 {
+		return nil, ErrBadPolygon
+	}
+
+	area := signedArea(polygon)
+	if math.Abs(area) < epsilon {
+		return nil, ErrBadPolygon
+	}
+
+	indexes := make([]int, 0, len(polygon))
+	for _, v := range polygon {
+		indexes = append(indexes, len(mesh.Vertices))
+		mesh.Vertices = append(mesh.Vertices, v)
+	}
+
+	if (area > 0) != ccw {
+		slices.Reverse(indexes)
+	}
+
+	return indexes, nil
+}
+
+func (mesh *Mesh) maxX(poly []int) float64 {
+	x := math.Inf(-1)
+	for _, v := range poly {
+		x = math.Max(x, mesh.Vertices[v].X)
+	}
+
+	return x
+}
+
+// bridgeHole joins a clockwise hole to the counter clockwise poly through a
+// pair of coincident edges between the rightmost hole vertex and a visible
+// poly vertex.
+func (mesh *Mesh) bridgeHole(poly, hole []int) []int {
+	holeStart := 0
+	for i, v := range hole {
+		if mesh.Vertices[v].X > mesh.Vertices[hole[holeStart]].X {
+			holeStart = i
+		}
+	}
+
+	m := mesh.Vertices[hole[holeStart]]
+
+	// cast a ray to +x and find the closest poly edge it hits
+	bestX := math.Inf(1)
+	bridge := -1
+	var hit geo.Vec2[float64]
+
+	for i := range poly {
+		a := mesh.Vertices[poly[i]]
+		b := mesh.Vertices[poly[(i+1)%len(poly)]]
+		if a.Y == b.Y || m.Y < math.Min(a.Y, b.Y) || m.Y > math.Max(a.Y, b.Y) {
+			continue
+		}
+
+		x := a.X + (m.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y)
+		if x < m.X || x >= bestX {
+			continue
+		}
+
+		bestX = x
+		hit = geo.Vec2[float64]{X: x, Y: m.Y}
+
+		switch {
+		case a == hit:
+			bridge = i
+		case b == hit:
+			bridge = (i + 1) % len(poly)
+		case a.X > b.X:
+			bridge = i
+		default:
+			bridge = (i + 1) % len(poly)
+		}
+	}
+
+	if bridge < 0 {
+		return poly
+	}
+
+	// a reflex vertex inside the triangle m, hit, bridge would block the view,
+	// take the one closest in angle to the ray instead
+	p := mesh.Vertices[poly[bridge]]
+	if p != hit {
+		bestCos := -2.0
+		bestDist := math.Inf(1)
+
+		for i, v := range poly {
+			pos := mesh.Vertices[v]
+			if i == bridge || pos == m || !mesh.isReflex(poly, i) {
+				continue
+			}
+
+			if !inTriangle(pos, m, hit, p) && !inTriangle(pos, m, p, hit) {
+				continue
+			}
+
+			delta := pos.Sub(m)
+			dist := delta.Len()
+			if cos := delta.X / dist; cos > bestCos+epsilon || math.Abs(cos-bestCos) <= epsilon && dist < bestDist {
+				bestCos = cos
+				bestDist = dist
+				bridge = i
+			}
+		}
+	}
+
+	merged := make([]int, 0, len(poly)+len(hole)+2)
+	merged = append(merged, poly[:bridge+1]...)
+	for i := 0; i <= len(hole); i++ {
+		merged = append(merged, hole[(holeStart+i)%len(hole)])
+	}
+	merged = append(merged, poly[bridge:]...)
+
+	return merged
+}
+
+func (mesh *Mesh) isReflex(poly []int, i int) bool {
+	prev := mesh.Vertices[poly[(i+len(poly)-1)%len(poly)]]
+	cur := mesh.Vertices[poly[i]]
+	next := mesh.Vertices[poly[(i+1)%len(poly)]]
+
+	return cur.Sub(prev).Cross(next.Sub(cur)) < 0
+}
+
+// triangulate clips ears from the counter clockwise poly.
+func (mesh *Mesh) triangulate(poly []int) error {
+	poly = slices.Clone(poly)
+
+	for len(poly) > 3 {
+		clipped := false
+
+		for i := range poly {
+			prevIndex := (i + len(poly) - 1) % len(poly)
+			nextIndex := (i + 1) % len(poly)
+			prev := mesh.Vertices[poly[prevIndex]]
+			cur := mesh.Vertices[poly[i]]
+			next := mesh.Vertices[poly[nextIndex]]
+
+			cross := cur.Sub(prev).Cross(next.Sub(cur))
+			if math.Abs(cross) <= epsilon {
+				// a collinear or doubled vertex adds no area
+				if cur.Sub(prev).Dot(next.Sub(cur)) >= 0 || cur == prev || cur == next {
+					poly = slices.Delete(poly, i, i+1)
+					clipped = true
+					break
+				}
+
+				continue
+			}
+
+			if cross < 0 || !mesh.isEar(poly, prevIndex, i, nextIndex) {
+				continue
+			}
+
+			mesh.Triangles = append(mesh.Triangles, Triangle{
+				Vertices:  [3]int{poly[prevIndex], poly[i], poly[nextIndex]},
+				Neighbors: [3]int{-1, -1, -1},
+			})
+			poly = slices.Delete(poly, i, i+1)
+			clipped = true
+			break
+		}
+
+		if !clipped {
+			return ErrTriangulate
+		}
+	}
+
+	a, b, c := mesh.Vertices[poly[0]], mesh.Vertices[poly[1]], mesh.Vertices[poly[2]]
+	if b.Sub(a).Cross(c.Sub(b)) > epsilon {
+		mesh.Triangles = append(mesh.Triangles, Triangle{
+			Vertices:  [3]int{poly[0], poly[1], poly[2]},
+			Neighbors: [3]int{-1, -1, -1},
+		})
+	}
+
+	return nil
+}
+
+func (mesh *Mesh) isEar(poly []int, prevIndex, index, nextIndex int) bool {
+	a := mesh.Vertices[poly[prevIndex]]
+	b := mesh.Vertices[poly[index]]
+	c := mesh.Vertices[poly[nextIndex]]
+
+	for i, v := range poly {
+		if i == prevIndex || i == index || i == nextIndex {
+			continue
+		}
+
+		pos := mesh.Vertices[v]
+		if pos == a || pos == b || pos == c {
+			continue
+		}
+
+		if inTriangle(pos, a, b, c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// link fills the triangle neighbors from their shared edges.
+func (mesh *Mesh) link() {
+	type edgeKey [4]float64
+	edges := make(map[edgeKey][2]int)
+
+	for i, tri := range mesh.Triangles {
+		for k := 0; k < 3; k++ {
+			a := mesh.Vertices[tri.Vertices[k]]
+			b := mesh.Vertices[tri.Vertices[(k+1)%3]]
+
+			key := edgeKey{b.X, b.Y, a.X, a.Y}
+			if other, ok := edges[key]; ok {
+				mesh.Triangles[i].Neighbors[k] = other[0]
+				mesh.Triangles[other[0]].Neighbors[other[1]] = i
+				delete(edges, key)
+				continue
+			}
+
+			edges[edgeKey{a.X, a.Y, b.X, b.Y}] = [2]int{i, k}
+		}
+	}
+}
+
+// Locate returns the triangle containing pos.
+func (mesh *Mesh) Locate(pos geo.Vec2[float64]) (int, bool) {
+	for i, tri := range mesh.Triangles {
+		if inTriangle(pos, mesh.Vertices[tri.Vertices[0]], mesh.Vertices[tri.Vertices[1]], mesh.Vertices[tri.Vertices[2]]) {
+			return i, true
+		}
+	}
+
+	return -1, false
+}
+
+// inTriangle tests pos against the counter clockwise triangle a, b, c, points
+// on its edges are inside.
+func inTriangle(pos, a, b, c geo.Vec2[float64]) bool {
+	return b.Sub(a).Cross(pos.Sub(a)) >= -epsilon &&
+		c.Sub(b).Cross(pos.Sub(b)) >= -epsilon &&
+		a.Sub(c).Cross(pos.Sub(c)) >= -epsilon
+}
+
+func signedArea(polygon []geo.Vec2[float64]) float64 {
+	area := 0.0
+	for i, v := range polygon {
+		area += v.Cross(polygon[(i+1)%len(polygon)])
+	}
+
+	return area / 2
+}
+
+func compareFloat(a, b float64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+
+	return 0
+}
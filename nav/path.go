@@ -0,0 +1,236 @@
+package nav
+
+import (
+	"container/heap"
+	"slices"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+type portal struct {
+	left  geo.Vec2[float64]
+	right geo.Vec2[float64]
+}
+
+// FindPath returns the shortest path from start (excluded) to end for an
+// agent of the given radius, portals narrower than the agent are closed and
+// the others are shrunk by radius at both ends.
+func (mesh *Mesh) FindPath(start, end geo.Vec2[float64], radius float64) ([]geo.Vec2[float64], error) {
+	startTri, ok := mesh.Locate(start)
+	if !ok {
+		return nil, ErrOutside
+	}
+
+	endTri, ok := mesh.Locate(end)
+	if !ok {
+		return nil, ErrOutside
+	}
+
+	corridor, ok := mesh.findCorridor(start, end, startTri, endTri, radius)
+	if !ok {
+		return nil, ErrUnreachable
+	}
+
+	portals := make([]portal, 0, len(corridor)+1)
+	portals = append(portals, portal{left: start, right: start})
+	for i := 0; i < len(corridor)-1; i++ {
+		p, _ := mesh.getPortal(corridor[i], corridor[i+1], radius)
+		portals = append(portals, p)
+	}
+	portals = append(portals, portal{left: end, right: end})
+
+	return stringPull(portals), nil
+}
+
+// getPortal returns the edge from tri to next seen from inside tri, shrunk by
+// radius. ok is false when the edge is too narrow.
+func (mesh *Mesh) getPortal(tri, next int, radius float64) (p portal, ok bool) {
+	t := mesh.Triangles[tri]
+	k := slices.Index(t.Neighbors[:], next)
+	if k < 0 {
+		return
+	}
+
+	p.right = mesh.Vertices[t.Vertices[k]]
+	p.left = mesh.Vertices[t.Vertices[(k+1)%3]]
+
+	if radius <= 0 {
+		return p, true
+	}
+
+	delta := p.left.Sub(p.right)
+	width := delta.Len()
+	if width <= 2*radius {
+		return p, false
+	}
+
+	delta.X, delta.Y = delta.X/width*radius, delta.Y/width*radius
+	p.right = p.right.Add(delta)
+	p.left = p.left.Sub(delta)
+
+	return p, true
+}
+
+type corridorNode struct {
+	tri    int
+	f      float64
+	serial int
+}
+
+type corridorQueue []corridorNode
+
+func (q corridorQueue) Len() int { return len(q) }
+func (q corridorQueue) Less(i, j int) bool {
+	return q[i].f < q[j].f || q[i].f == q[j].f && q[i].serial < q[j].serial
+}
+func (q corridorQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *corridorQueue) Push(x any)   { *q = append(*q, x.(corridorNode)) }
+func (q *corridorQueue) Pop() any {
+	old := *q
+	node := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return node
+}
+
+// findCorridor runs A* over the triangles, a triangle is entered at the middle
+// of its portal and costs the distance walked from the previous entry point,
+// the end triangle also costs the walk on to end.
+func (mesh *Mesh) findCorridor(start, end geo.Vec2[float64], startTri, endTri int, radius float64) ([]int, bool) {
+	count := len(mesh.Triangles)
+	gs := make([]float64, count)
+	parents := make([]int, count)
+	entries := make([]geo.Vec2[float64], count)
+	states := make([]uint8, count)
+
+	const (
+		stateOpen = iota + 1
+		stateClose
+	)
+
+	queue := &corridorQueue{}
+	serial := 0
+
+	parents[startTri] = -1
+	entries[startTri] = start
+	states[startTri] = stateOpen
+	heap.Push(queue, corridorNode{tri: startTri, f: start.Sub(end).Len()})
+
+	for queue.Len() > 0 {
+		node := heap.Pop(queue).(corridorNode)
+		if states[node.tri] == stateClose {
+			continue
+		}
+
+		states[node.tri] = stateClose
+		if node.tri == endTri {
+			break
+		}
+
+		for _, next := range mesh.Triangles[node.tri].Neighbors {
+			if next < 0 || states[next] == stateClose {
+				continue
+			}
+
+			p, ok := mesh.getPortal(node.tri, next, radius)
+			if !ok {
+				continue
+			}
+
+			entry := geo.Vec2[float64]{X: (p.left.X + p.right.X) / 2, Y: (p.left.Y + p.right.Y) / 2}
+			g := gs[node.tri] + entry.Sub(entries[node.tri]).Len()
+			if next == endTri {
+				// the end triangle is left at end, so parents compare by the
+				// whole walk instead of the first one to reach it
+				g += entry.Sub(end).Len()
+			}
+
+			if states[next] == stateOpen && g >= gs[next] {
+				continue
+			}
+
+			gs[next] = g
+			parents[next] = node.tri
+			entries[next] = entry
+			states[next] = stateOpen
+
+			serial++
+			f := g
+			if next != endTri {
+				f += entry.Sub(end).Len()
+			}
+
+			heap.Push(queue, corridorNode{tri: next, f: f, serial: serial})
+		}
+	}
+
+	if states[endTri] != stateClose {
+		return nil, false
+	}
+
+	corridor := make([]int, 0)
+	for tri := endTri; tri >= 0; tri = parents[tri] {
+		corridor = append(corridor, tri)
+	}
+	slices.Reverse(corridor)
+
+	return corridor, true
+}
+
+// stringPull is the simple stupid funnel algorithm, the first portal is the
+// start point and the last one the end point.
+func stringPull(portals []portal) []geo.Vec2[float64] {
+	path := []geo.Vec2[float64]{portals[0].left}
+
+	apex := portals[0].left
+	left, right := portals[0].left, portals[0].right
+	apexIndex, leftIndex, rightIndex := 0, 0, 0
+
+	for i := 1; i < len(portals); i++ {
+		pLeft, pRight := portals[i].left, portals[i].right
+
+		// tighten the right side
+		if right.Sub(apex).Cross(pRight.Sub(apex)) >= 0 {
+			if apex == right || left.Sub(apex).Cross(pRight.Sub(apex)) < 0 {
+				right = pRight
+				rightIndex = i
+			} else {
+				// right crossed over left, left becomes a corner
+				apex = left
+				apexIndex = leftIndex
+				path = appendPoint(path, apex)
+
+				left, right = apex, apex
+				leftIndex, rightIndex = apexIndex, apexIndex
+				i = apexIndex
+				continue
+			}
+		}
+
+		// tighten the left side
+		if left.Sub(apex).Cross(pLeft.Sub(apex)) <= 0 {
+			if apex == left || right.Sub(apex).Cross(pLeft.Sub(apex)) > 0 {
+				left = pLeft
+				leftIndex = i
+			} else {
+				apex = right
+				apexIndex = rightIndex
+				path = appendPoint(path, apex)
+
+				left, right = apex, apex
+				leftIndex, rightIndex = apexIndex, apexIndex
+				i = apexIndex
+				continue
+			}
+		}
+	}
+
+	return appendPoint(path, portals[len(portals)-1].left)[1:]
+}
+
+func appendPoint(path []geo.Vec2[float64], pos geo.Vec2[float64]) []geo.Vec2[float64] {
+	if len(path) > 0 && path[len(path)-1] == pos {
+		return path
+	}
+
+	return append(path, pos)
+}
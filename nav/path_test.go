@@ -0,0 +1,63 @@
+package nav
+
+import (
+	"math"
+	"testing"
+
+	"github.com/xtxy/cxlib/geo"
+)
+
+func rect(minX, minY, maxX, maxY float64) []geo.Vec2[float64] {
+	return []geo.Vec2[float64]{{X: minX, Y: minY}, {X: maxX, Y: minY}, {X: maxX, Y: maxY}, {X: minX, Y: maxY}}
+}
+
+func pathLength(start geo.Vec2[float64], path []geo.Vec2[float64]) float64 {
+	var length float64
+	prev := start
+	for _, v := range path {
+		length += v.Sub(prev).Len()
+		prev = v
+	}
+
+	return length
+}
+
+func TestFindPathLength(t *testing.T) {
+	cases := []struct {
+		name   string
+		holes  [][]geo.Vec2[float64]
+		start  geo.Vec2[float64]
+		end    geo.Vec2[float64]
+		length float64
+	}{
+		{"open", nil, geo.Vec2[float64]{X: 1, Y: 1}, geo.Vec2[float64]{X: 9, Y: 7}, 10},
+		{"gap", [][]geo.Vec2[float64]{rect(2, 2, 4, 8), rect(6, 2, 8, 8)},
+			geo.Vec2[float64]{X: 5, Y: 9}, geo.Vec2[float64]{X: 5, Y: 1}, 8},
+		{"around", [][]geo.Vec2[float64]{rect(3, 3, 7, 7)},
+			geo.Vec2[float64]{X: 5, Y: 1}, geo.Vec2[float64]{X: 5, Y: 9}, 4 + 4*math.Sqrt2},
+		{"corners", [][]geo.Vec2[float64]{rect(2, 2, 4, 8), rect(6, 2, 8, 8)},
+			geo.Vec2[float64]{X: 1, Y: 5}, geo.Vec2[float64]{X: 9, Y: 5},
+			6 + 2*math.Hypot(1, 3)},
+	}
+
+	for _, v := range cases {
+		mesh, err := NewMesh(rect(0, 0, 10, 10), v.holes...)
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+
+		path, err := mesh.FindPath(v.start, v.end, 0)
+		if err != nil {
+			t.Errorf("%s: %v", v.name, err)
+			continue
+		}
+
+		if path[len(path)-1] != v.end {
+			t.Errorf("%s: path %v does not end at %v", v.name, path, v.end)
+		}
+
+		if length := pathLength(v.start, path); math.Abs(length-v.length) > 1e-9 {
+			t.Errorf("%s: path %v length %v, want %v", v.name, path, length, v.length)
+		}
+	}
+}